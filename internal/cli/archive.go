@@ -0,0 +1,332 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/archive"
+	"github.com/moshebe/gtrace/pkg/filter"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+)
+
+func archiveDirFlag() cli.Flag {
+	return &cli.PathFlag{
+		Name:    "dir",
+		Aliases: []string{"d"},
+		Usage:   "archive directory. defaults to $XDG_DATA_HOME/gtrace/archive",
+	}
+}
+
+func openArchive(c *cli.Context) (*archive.Archive, error) {
+	dir := c.String("dir")
+	if dir == "" {
+		var err error
+		if dir, err = archive.DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return archive.Open(dir)
+}
+
+// listsTraces reports whether a command reading traces from files or listing them from the API lists them: only
+// when no file is given and a project is set, on the command line or by the profile. '-' reads stdin.
+func listsTraces(c *cli.Context) bool {
+	return c.NArg() == 0 && c.String("project") != ""
+}
+
+// collectTraces reads traces from files, or from every trace file in directories such as the ones written by
+// fetch-all or get --out-dir.
+func collectTraces(paths []string) ([]*cloudtrace.Trace, error) {
+	var traces []*cloudtrace.Trace
+	for _, path := range paths {
+		files := []string{path}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range files {
+			if filepath.Base(file) == fetchQueryFile {
+				continue
+			}
+			results, err := readTraces(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			traces = append(traces, results...)
+		}
+	}
+	return traces, nil
+}
+
+var archiveAddAction = func(c *cli.Context) error {
+	ids := stringSlice(c, "id")
+	projects := stringSlice(c, "project")
+	if len(ids) > 0 && len(projects) == 0 {
+		return fmt.Errorf("missing project")
+	}
+	if len(ids) == 0 && c.NArg() == 0 {
+		return fmt.Errorf("missing trace files or ids")
+	}
+
+	traces, err := collectTraces(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	if len(ids) > 0 {
+		ctx, cancel := context.WithTimeout(c.Context, time.Minute)
+		defer cancel()
+
+		trc, err := tracer.NewTracer(ctx, tracerOptions(c)...)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = trc.Close() }()
+
+		for _, id := range ids {
+			trace, err := trc.MultiGet(ctx, projects, []string{id})
+			if err != nil {
+				return fmt.Errorf("get trace: %w", err)
+			}
+			traces = append(traces, trace)
+		}
+	}
+
+	store, err := openArchive(c)
+	if err != nil {
+		return err
+	}
+	if err = store.Add(traces...); err != nil {
+		return err
+	}
+	fmt.Printf("archived %d traces in %s\n", len(traces), store.Dir())
+	return nil
+}
+
+func archiveQuery(c *cli.Context) (*archive.Query, error) {
+	q := &archive.Query{
+		Project:     c.String("project"),
+		MinDuration: c.Duration("min-duration"),
+		MaxDuration: c.Duration("max-duration"),
+	}
+
+	if roots := c.StringSlice("root"); len(roots) > 0 {
+		f, err := filter.New(roots, true)
+		if err != nil {
+			return nil, fmt.Errorf("root filter: %w", err)
+		}
+		q.Root = f
+	}
+
+	labels := make(map[string][]string)
+	for _, label := range c.StringSlice("label") {
+		key, pattern, found := strings.Cut(label, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid label condition %q, expected key=regex", label)
+		}
+		labels[key] = append(labels[key], pattern)
+	}
+	q.Labels = make(map[string]*filter.Filter, len(labels))
+	for key, patterns := range labels {
+		f, err := filter.New(patterns, true)
+		if err != nil {
+			return nil, fmt.Errorf("label %q filter: %w", key, err)
+		}
+		q.Labels[key] = f
+	}
+
+	if c.IsSet("since") {
+		q.From = time.Now().Add(-c.Duration("since"))
+	}
+	if ts := c.Timestamp("start"); ts != nil {
+		q.From = *ts
+	}
+	if ts := c.Timestamp("end"); ts != nil {
+		q.To = *ts
+	}
+	return q, nil
+}
+
+var archiveSearchAction = func(c *cli.Context) error {
+	q, err := archiveQuery(c)
+	if err != nil {
+		return err
+	}
+	store, err := openArchive(c)
+	if err != nil {
+		return err
+	}
+	records, err := store.Search(q)
+	if err != nil {
+		return err
+	}
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(records, "", "\t")
+		} else {
+			output, err = json.Marshal(records)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "TRACE\tPROJECT\tSTART\tDURATION\tSPANS\tROOT")
+		for _, r := range records {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", r.TraceID, r.Project,
+				r.Start.Local().Format(time.DateTime), r.Duration(), r.Spans, r.Root)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
+}
+
+var archiveGetAction = func(c *cli.Context) error {
+	id := c.Args().First()
+	if id == "" {
+		return fmt.Errorf("missing trace id")
+	}
+	store, err := openArchive(c)
+	if err != nil {
+		return err
+	}
+
+	project := c.String("project")
+	if project == "" {
+		records, err := store.Records()
+		if err != nil {
+			return err
+		}
+		var projects []string
+		for _, r := range records {
+			if r.TraceID == id {
+				projects = append(projects, r.Project)
+			}
+		}
+		switch len(projects) {
+		case 0:
+			return fmt.Errorf("trace %q is not archived", id)
+		case 1:
+			project = projects[0]
+		default:
+			return fmt.Errorf("trace %q is archived in multiple projects (%s), use --project", id, strings.Join(projects, ", "))
+		}
+	}
+
+	trace, err := store.Get(project, id)
+	if err != nil {
+		return err
+	}
+	return printTraceJSON(os.Stdout, trace)
+}
+
+var ArchiveCommand = &cli.Command{
+	Name:  "archive",
+	Usage: "Keep traces in a durable local archive and search them offline",
+	Description: "Cloud Trace keeps traces for 30 days only. The archive stores traces on disk along with an index " +
+		"that can be searched by root span name, label values, time range and duration without network access.",
+	UsageText: "gtrace archive <add|search|get>",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "add",
+			Usage:     "Archive traces from files, directories or fetched by id",
+			UsageText: "gtrace archive add [command options] [file or directory...]",
+			Action:    archiveAddAction,
+			Flags: append([]cli.Flag{
+				archiveDirFlag(),
+				&cli.StringSliceFlag{
+					Name:    "project",
+					Aliases: []string{"p"},
+					Usage:   "the Google Cloud project ID to fetch traces from. values can be set multiple times or separated by comma",
+				},
+				&cli.StringSliceFlag{
+					Name:  "id",
+					Usage: "trace id to fetch and archive. values can be set multiple times or separated by comma",
+				},
+			}, cacheFlags()...),
+		},
+		{
+			Name:      "search",
+			Usage:     "Search the archived traces",
+			UsageText: "gtrace archive search [command options]",
+			Action:    archiveSearchAction,
+			Flags: []cli.Flag{
+				archiveDirFlag(),
+				&cli.StringFlag{
+					Name:    "project",
+					Aliases: []string{"p"},
+					Usage:   "only traces of the given project",
+				},
+				&cli.StringSliceFlag{
+					Name:  "root",
+					Usage: "root span name regex. can be set multiple times",
+				},
+				&cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "key=regex condition on span label values. can be set multiple times",
+				},
+				&cli.DurationFlag{
+					Name:  "since",
+					Usage: "only traces started within this duration since now",
+				},
+				&cli.TimestampFlag{
+					Name:   "start",
+					Layout: "2006-01-02T15:04:05",
+					Usage:  "only traces started at or after this time",
+				},
+				&cli.TimestampFlag{
+					Name:   "end",
+					Layout: "2006-01-02T15:04:05",
+					Usage:  "only traces started at or before this time",
+				},
+				&cli.DurationFlag{
+					Name:  "min-duration",
+					Usage: "only traces lasting at least this duration",
+				},
+				&cli.DurationFlag{
+					Name:  "max-duration",
+					Usage: "only traces lasting at most this duration",
+				},
+				&cli.BoolFlag{
+					Name:  "pretty",
+					Usage: "prettify JSON output",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Value: "text",
+					Usage: "output format: json or text",
+				},
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "Print an archived trace",
+			UsageText: "gtrace archive get [command options] <trace-id>",
+			Action:    archiveGetAction,
+			Flags: []cli.Flag{
+				archiveDirFlag(),
+				&cli.StringFlag{
+					Name:    "project",
+					Aliases: []string{"p"},
+					Usage:   "project of the archived trace. required only if the trace id is archived in multiple projects",
+				},
+			},
+		},
+	},
+}
@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/assert"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+)
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func writeJUnit(path string, results []assert.Result) error {
+	suite := junitSuite{Name: "gtrace assert", Tests: len(results)}
+	for _, r := range results {
+		tc := junitCase{Name: r.Name, ClassName: "gtrace.assert"}
+		if !r.Passed {
+			suite.Failures++
+			tc.Failure = &junitFailure{Message: r.Message, Text: fmt.Sprintf("%s\nobserved: %s", r.Rule, r.Observed)}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	output, err := xml.MarshalIndent(suite, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshal junit report: %w", err)
+	}
+	if err = os.WriteFile(path, append([]byte(xml.Header), output...), 0o644); err != nil {
+		return fmt.Errorf("write junit report: %w", err)
+	}
+	return nil
+}
+
+// assertTraces reads the traces of the given paths, ending at the latest span end, or lists the traces of the project
+// if no path is given, ending now. Without paths nor project, traces are read from stdin. It returns the traces and
+// the time rule windows end at.
+func assertTraces(c *cli.Context, rules []assert.Rule) ([]*cloudtrace.Trace, time.Time, error) {
+	if !listsTraces(c) {
+		paths := c.Args().Slice()
+		if len(paths) == 0 {
+			paths = []string{"-"}
+		}
+		traces, err := collectTraces(paths)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		var now time.Time
+		for _, t := range traces {
+			if _, end := span.Interval(t.GetSpans()); end.After(now) {
+				now = end
+			}
+		}
+		return traces, now, nil
+	}
+
+	since := c.Duration("since")
+	if !c.IsSet("since") {
+		if window := assert.MaxWindow(rules); window > 0 {
+			since = window
+		}
+	}
+
+	ctx := context.Background()
+	trc, err := tracer.NewTracer(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer func() { _ = trc.Close() }()
+
+	now := time.Now()
+	opts := []tracer.ListOption{
+		tracer.WithStartTime(now.Add(-since)),
+		tracer.WithEndTime(now),
+		tracer.WithFilter(c.StringSlice("filter")...),
+	}
+	traces, err := trc.List(ctx, c.String("project"), int32(c.Int("limit")), opts...)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("list traces: %w", err)
+	}
+	return traces, now, nil
+}
+
+var assertAction = func(c *cli.Context) error {
+	f, err := os.Open(c.Path("rules"))
+	if err != nil {
+		return fmt.Errorf("open rules: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	rules, err := assert.Parse(f)
+	if err != nil {
+		return fmt.Errorf("parse rules: %w", err)
+	}
+
+	traces, now, err := assertTraces(c, rules)
+	if err != nil {
+		return err
+	}
+
+	converted := toModel(traces)
+	failures := 0
+	results := make([]assert.Result, 0, len(rules))
+	for _, rule := range rules {
+		result := rule.Evaluate(converted, now)
+		if !result.Passed {
+			failures++
+		}
+		results = append(results, result)
+	}
+
+	if path := c.Path("junit"); path != "" {
+		if err = writeJUnit(path, results); err != nil {
+			return err
+		}
+	}
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(results, "", "\t")
+		} else {
+			output, err = json.Marshal(results)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		for _, r := range results {
+			status := "PASS"
+			if !r.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("%s %s (%s over %d traces)\n", status, r.Name, r.Observed, r.Traces)
+			if r.Message != "" {
+				fmt.Printf("  %s\n", r.Message)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+
+	if failures > 0 {
+		return cli.Exit(fmt.Sprintf("%d of %d rules failed", failures, len(results)), 1)
+	}
+	return nil
+}
+
+var AssertCommand = &cli.Command{
+	Name:  "assert",
+	Usage: "Check traces against latency, error and span count rules",
+	Description: "Evaluate the rules of a file against the traces read from the given files and directories, '-' being " +
+		"stdin, or listed from --project, or the profile project, when none is given, and read from stdin otherwise. Exits with a non-zero code if any rule fails. One rule per line:\n\n" +
+		"   [<name>:] [root <pattern>] span <pattern> <metric> <op> <value> [over <duration>]\n\n" +
+		"Patterns are regular expressions matched against span names, '*' matches any span. Metrics are p50, p95, " +
+		"p99, min, max and mean compared to durations, errors, the number of failed spans, and count, the number of " +
+		"spans per trace. Windows end now when listing traces, or at the latest span end when reading files. " +
+		"When listing, traces are listed over the largest window of the rules unless --since is set. For example:\n\n" +
+		"   pay-latency: span ^/api/pay$ p99 < 300ms over 30m\n" +
+		"   root ^/api/checkout$ span * errors == 0\n" +
+		"   span ^db count <= 20",
+	UsageText: "gtrace assert --rules <file> [command options] [trace files or directories...]",
+	Action:    assertAction,
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:     "rules",
+			Aliases:  []string{"r"},
+			Required: true,
+			Usage:    "rules file path",
+		},
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "the Google Cloud project ID to list traces from",
+		},
+		&cli.DurationFlag{
+			Name:  "since",
+			Value: time.Hour,
+			Usage: "time duration to list traces since now, when no rule sets a window",
+		},
+		&cli.StringSliceFlag{
+			Name:    "filter",
+			Aliases: []string{"f"},
+			Usage:   "filter the listed traces according to Cloud Trace API syntax. can be set multiple times. See: https://cloud.google.com/trace/docs/trace-filters#filter_syntax",
+		},
+		queryFlag(),
+		&cli.IntFlag{
+			Name:  "limit",
+			Value: 500,
+			Usage: "maximum number of traces to list",
+		},
+		&cli.PathFlag{
+			Name:  "junit",
+			Usage: "write a JUnit XML report to the given path",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	},
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/cache"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+)
+
+func cacheFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "no-cache",
+			Usage: "neither read from nor write to the local trace cache",
+		},
+		&cli.BoolFlag{
+			Name:  "refresh",
+			Usage: "ignore cached results and fetch from the API, storing the results in the local trace cache",
+		},
+	}
+}
+
+// tracerOptions returns the tracer options matching the cache flags. The cache is best-effort, so a cache
+// directory that cannot be used just disables caching.
+func tracerOptions(c *cli.Context) []tracer.Option {
+	if c.Bool("no-cache") {
+		return nil
+	}
+	store, err := openCache()
+	if err != nil {
+		return nil
+	}
+	opts := []tracer.Option{tracer.WithCache(store)}
+	if c.Bool("refresh") {
+		opts = append(opts, tracer.WithRefresh())
+	}
+	return opts
+}
+
+func openCache(opts ...cache.Option) (*cache.Cache, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir, opts...)
+}
+
+var cacheListAction = func(c *cli.Context) error {
+	store, err := openCache()
+	if err != nil {
+		return err
+	}
+	entries, err := store.Entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "KEY\tSIZE\tCREATED\tACCESSED")
+	for _, e := range entries {
+		total += e.Size
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.Key, e.Size,
+			e.Created.Local().Format(time.DateTime), e.Accessed.Local().Format(time.DateTime))
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d entries, %d bytes in %s\n", len(entries), total, store.Dir())
+	return nil
+}
+
+var cachePruneAction = func(c *cli.Context) error {
+	store, err := openCache(cache.WithTTL(c.Duration("ttl")), cache.WithMaxSize(c.Int64("max-size")<<20))
+	if err != nil {
+		return err
+	}
+	removed, err := store.Prune()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("removed %d entries\n", removed)
+	return nil
+}
+
+var cacheClearAction = func(c *cli.Context) error {
+	store, err := openCache()
+	if err != nil {
+		return err
+	}
+	removed, err := store.Clear()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("removed %d entries\n", removed)
+	return nil
+}
+
+var CacheCommand = &cli.Command{
+	Name:  "cache",
+	Usage: "Inspect and manage the local trace cache",
+	Description: "Traces fetched by get and list results are cached under the user cache directory, keyed by project and trace id. " +
+		"Traces and list windows ending less than 10 minutes ago, as well as lists without a start and an end, are not " +
+		"cached since they may still change. Use --no-cache or --refresh on these commands to bypass the cache.",
+	UsageText: "gtrace cache <ls|prune|clear>",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "ls",
+			Usage:     "List the cached entries, most recently used first",
+			UsageText: "gtrace cache ls",
+			Action:    cacheListAction,
+		},
+		{
+			Name:      "prune",
+			Usage:     "Remove expired entries and evict the least recently used ones beyond the size cap",
+			UsageText: "gtrace cache prune [command options]",
+			Action:    cachePruneAction,
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:  "ttl",
+					Value: cache.DefaultTTL,
+					Usage: "remove entries stored longer than this duration ago",
+				},
+				&cli.Int64Flag{
+					Name:  "max-size",
+					Value: cache.DefaultMaxSize >> 20,
+					Usage: "maximum total size of the cache in MiB",
+				},
+			},
+		},
+		{
+			Name:      "clear",
+			Usage:     "Remove all the cached entries",
+			UsageText: "gtrace cache clear",
+			Action:    cacheClearAction,
+		},
+	},
+}
@@ -1,15 +1,23 @@
 package cli
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	tracev2 "cloud.google.com/go/trace/apiv2/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
 	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 func App(version string) *cli.App {
-	return &cli.App{
+	app := &cli.App{
 		Name:      "gtrace",
 		Version:   version,
 		HelpName:  "gtrace",
@@ -22,8 +30,30 @@ func App(version string) *cli.App {
 			FormatCommand,
 			DurationCommand,
 			SubtreeCommand,
+			FetchAllCommand,
+			CacheCommand,
+			ArchiveCommand,
+			PutCommand,
+			RedactCommand,
+			ValidateCommand,
+			RPCCommand,
+			ErrorsCommand,
+			PatternsCommand,
+			ConcurrencyCommand,
+			GapsCommand,
+			CompareCommand,
+			OutliersCommand,
+			AssertCommand,
+			WatchCommand,
+			TUICommand,
+			ServeCommand,
+			ConfigCommand,
 		},
+		Flags:  configFlags(),
+		Before: loadProfile,
 	}
+	withProfile(app.Commands)
+	return app
 }
 
 func stringSlice(c *cli.Context, name string) []string {
@@ -34,9 +64,89 @@ func stringSlice(c *cli.Context, name string) []string {
 	return results
 }
 
+func sortedKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func read(path string) ([]byte, error) {
 	if path == "-" {
 		return io.ReadAll(os.Stdin)
 	}
 	return os.ReadFile(path)
 }
+
+// readTraces reads either a single trace, newline delimited traces, as written by get --separate, or v2 spans in
+// the format of a BatchWriteSpans request, which are converted to v1 traces.
+func readTraces(path string) ([]*cloudtrace.Trace, error) {
+	traces, converted, err := parseTraces(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range converted {
+		traces = append(traces, t.V1())
+	}
+	return traces, nil
+}
+
+// readModelTraces reads traces like readTraces into the format-neutral model the analyses run on.
+func readModelTraces(path string) ([]*model.Trace, error) {
+	traces, converted, err := parseTraces(path)
+	if err != nil {
+		return nil, err
+	}
+	return append(converted, toModel(traces)...), nil
+}
+
+// toModel converts v1 traces into the format-neutral model the analyses run on.
+func toModel(traces []*cloudtrace.Trace) []*model.Trace {
+	results := make([]*model.Trace, 0, len(traces))
+	for _, t := range traces {
+		results = append(results, model.FromV1(t))
+	}
+	return results
+}
+
+// parseTraces returns either the v1 traces or the traces of the v2 spans found in the file.
+func parseTraces(path string) ([]*cloudtrace.Trace, []*model.Trace, error) {
+	in, err := read(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var trace cloudtrace.Trace
+	if err = protojson.Unmarshal(in, &trace); err == nil {
+		return []*cloudtrace.Trace{&trace}, nil, nil
+	}
+
+	var batch tracev2.BatchWriteSpansRequest
+	if err = protojson.Unmarshal(in, &batch); err == nil && len(batch.GetSpans()) > 0 {
+		converted, err := model.FromV2(batch.GetSpans())
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, converted, nil
+	}
+
+	var traces []*cloudtrace.Trace
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		trace := &cloudtrace.Trace{}
+		if err = protojson.Unmarshal(scanner.Bytes(), trace); err != nil {
+			return nil, nil, fmt.Errorf("unmarshal trace at line %d: %w", line, err)
+		}
+		traces = append(traces, trace)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return traces, nil, nil
+}
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+type compareWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Traces int       `json:"traces"`
+}
+
+type compareResult struct {
+	Baseline compareWindow  `json:"baseline"`
+	Current  compareWindow  `json:"current"`
+	Shifts   []compareShift `json:"shifts"`
+}
+
+type compareShift struct {
+	span.Shift
+	Significant bool `json:"significant"`
+}
+
+// parseWindow parses a time window ending now, such as "1h", or ending some time ago, such as "24h-ago/1h".
+func parseWindow(value string, now time.Time) (time.Time, time.Time, error) {
+	end := now
+	length := value
+	if ago, rest, found := strings.Cut(value, "/"); found {
+		offset, err := time.ParseDuration(strings.TrimSuffix(ago, "-ago"))
+		if err != nil || !strings.HasSuffix(ago, "-ago") {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid window %q: expected <duration> or <duration>-ago/<duration>", value)
+		}
+		end = now.Add(-offset)
+		length = rest
+	}
+	d, err := time.ParseDuration(length)
+	if err != nil || d <= 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid window %q: expected <duration> or <duration>-ago/<duration>", value)
+	}
+	return end.Add(-d), end, nil
+}
+
+var compareAction = func(c *cli.Context) error {
+	if !c.IsSet("project") {
+		return fmt.Errorf("missing project")
+	}
+	now := time.Now()
+	baseStart, baseEnd, err := parseWindow(c.String("baseline"), now)
+	if err != nil {
+		return err
+	}
+	curStart, curEnd, err := parseWindow(c.String("current"), now)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	trc, err := tracer.NewTracer(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = trc.Close() }()
+
+	var baseline, current []*cloudtrace.Trace
+	list := func(start, end time.Time, traces *[]*cloudtrace.Trace) func() error {
+		return func() error {
+			opts := []tracer.ListOption{tracer.WithStartTime(start), tracer.WithEndTime(end), tracer.WithFilter(c.StringSlice("filter")...)}
+			results, err := trc.List(ctx, c.String("project"), int32(c.Int("limit")), opts...)
+			if err != nil {
+				return fmt.Errorf("list traces between %s and %s: %w", start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+			}
+			*traces = results
+			return nil
+		}
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(list(baseStart, baseEnd, &baseline))
+	g.Go(list(curStart, curEnd, &current))
+	if err = g.Wait(); err != nil {
+		return err
+	}
+
+	durations := func(traces []*cloudtrace.Trace) map[string][]time.Duration {
+		var spans []*model.Span
+		for _, trace := range toModel(traces) {
+			spans = append(spans, trace.Spans...)
+		}
+		return span.NameDurations(spans)
+	}
+
+	alpha, minCount := c.Float64("alpha"), c.Int("min-count")
+	result := compareResult{
+		Baseline: compareWindow{Start: baseStart, End: baseEnd, Traces: len(baseline)},
+		Current:  compareWindow{Start: curStart, End: curEnd, Traces: len(current)},
+		Shifts:   []compareShift{},
+	}
+	for _, s := range span.Shifts(durations(baseline), durations(current)) {
+		if s.Baseline.Count < minCount && s.Current.Count < minCount {
+			continue
+		}
+		result.Shifts = append(result.Shifts, compareShift{Shift: s, Significant: s.PValue < alpha})
+	}
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(result, "", "\t")
+		} else {
+			output, err = json.Marshal(result)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		fmt.Printf("baseline: %d traces between %s and %s\n", result.Baseline.Traces,
+			baseStart.Format(time.RFC3339), baseEnd.Format(time.RFC3339))
+		fmt.Printf("current: %d traces between %s and %s\n\n", result.Current.Traces,
+			curStart.Format(time.RFC3339), curEnd.Format(time.RFC3339))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "SPAN\tBASELINE\tCURRENT\tP50\tP50 DELTA\tP99\tP99 DELTA\tP-VALUE\t")
+		for _, s := range result.Shifts {
+			marker := ""
+			if s.Significant {
+				marker = "*"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%s -> %s\t%+.2fms\t%s -> %s\t%+.2fms\t%.4f\t%s\n", s.Name,
+				s.Baseline.Count, s.Current.Count, s.Baseline.P50, s.Current.P50, milliseconds(s.P50Delta),
+				s.Baseline.P99, s.Current.P99, milliseconds(s.P99Delta), s.PValue, marker)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+var CompareCommand = &cli.Command{
+	Name:  "compare",
+	Usage: "Compare span latencies between two time windows",
+	Description: "List the traces of both windows with the same filters and report, per span name, the p50 and p99 " +
+		"of each window, their deltas and the Mann-Whitney U test p-value, ordered by the largest p50 moves. Shifts " +
+		"below --alpha are marked as significant. A window is either a duration ending now, such as 1h, or a " +
+		"duration ending some time ago, such as 24h-ago/1h for the same hour yesterday.",
+	UsageText: "gtrace compare --project <project> --baseline 24h-ago/1h [--current 1h] [command options]",
+	Action:    compareAction,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "the Google Cloud project ID to use for this invocation",
+		},
+		&cli.StringFlag{
+			Name:     "baseline",
+			Required: true,
+			Usage:    "baseline window, such as 24h-ago/1h",
+		},
+		&cli.StringFlag{
+			Name:  "current",
+			Value: "1h",
+			Usage: "current window, such as 1h",
+		},
+		&cli.StringSliceFlag{
+			Name:    "filter",
+			Aliases: []string{"f"},
+			Usage:   "filter traces of both windows according to Cloud Trace API syntax. can be set multiple times. See: https://cloud.google.com/trace/docs/trace-filters#filter_syntax",
+		},
+		queryFlag(),
+		&cli.IntFlag{
+			Name:  "limit",
+			Value: 100,
+			Usage: "maximum number of traces to list per window",
+		},
+		&cli.IntFlag{
+			Name:  "min-count",
+			Value: 5,
+			Usage: "minimal number of spans of a name in either window to report it",
+		},
+		&cli.Float64Flag{
+			Name:  "alpha",
+			Value: 0.05,
+			Usage: "significance level of the shifts",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	},
+}
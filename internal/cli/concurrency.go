@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/urfave/cli/v2"
+)
+
+type concurrencyResult struct {
+	ProjectID string `json:"projectId,omitempty"`
+	TraceID   string `json:"traceId"`
+	span.Concurrency
+}
+
+var concurrencyAction = func(c *cli.Context) error {
+	traces, err := readModelTraces(c.String("file"))
+	if err != nil {
+		return err
+	}
+	minChildren, minSavings := c.Int("min-children"), c.Duration("min-savings")
+
+	results := []concurrencyResult{}
+	for _, trace := range traces {
+		adjustSkew(c, trace)
+		for _, cc := range span.Concurrencies(trace) {
+			if cc.Children < minChildren {
+				continue
+			}
+			var serializations []span.Serialization
+			for _, s := range cc.Serializations {
+				if s.Savings >= minSavings {
+					serializations = append(serializations, s)
+				}
+			}
+			cc.Serializations = serializations
+			if !c.Bool("timeline") {
+				cc.Timeline = nil
+			}
+			results = append(results, concurrencyResult{ProjectID: trace.ProjectID, TraceID: trace.TraceID, Concurrency: cc})
+		}
+	}
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(results, "", "\t")
+		} else {
+			output, err = json.Marshal(results)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		for _, r := range results {
+			fmt.Printf("%s %s (%d) - took %s, %d children, max %d concurrent, avg %.2f\n",
+				r.TraceID, r.Name, r.SpanID, r.Duration, r.Children, r.Max, r.Average)
+			if len(r.Timeline) > 0 {
+				points := make([]string, 0, len(r.Timeline))
+				for _, p := range r.Timeline {
+					points = append(points, fmt.Sprintf("+%s:%d", p.Offset, p.Count))
+				}
+				fmt.Printf("  in flight: %s\n", strings.Join(points, " "))
+			}
+			for _, s := range r.Serializations {
+				names := make([]string, 0, len(s.Spans))
+				for _, ref := range s.Spans {
+					names = append(names, fmt.Sprintf("%s (%d)", ref.Name, ref.SpanID))
+				}
+				fmt.Printf("  serial: %s took %s, parallel could save %s\n", strings.Join(names, " > "), s.Wall, s.Savings)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
+}
+
+var ConcurrencyCommand = &cli.Command{
+	Name:  "concurrency",
+	Usage: "Measure how parallel the children of each span ran",
+	Description: "Compute, per parent span, the maximum and average number of children running at once over the " +
+		"parent lifetime, optionally the timeline of in-flight children, and serialization opportunities: runs of " +
+		"sibling spans that ran back-to-back and, if independent, could run in parallel in the time of the slowest one.",
+	UsageText: "gtrace concurrency [command options]",
+	Action:    concurrencyAction,
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Value:   "-",
+			Usage:   "input file path. '-' means stdin",
+		},
+		&cli.IntFlag{
+			Name:  "min-children",
+			Value: 2,
+			Usage: "minimal number of children of a reported span",
+		},
+		&cli.DurationFlag{
+			Name:  "min-savings",
+			Value: time.Millisecond,
+			Usage: "minimal savings of a reported serialization opportunity",
+		},
+		&cli.BoolFlag{
+			Name:  "timeline",
+			Usage: "include the timeline of in-flight children",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	}, skewFlags()...),
+}
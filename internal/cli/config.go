@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+const profileMetadata = "profile"
+
+func configFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.PathFlag{
+			Name:    "config",
+			EnvVars: []string{"GTRACE_CONFIG"},
+			Usage:   "configuration file, defaults to $XDG_CONFIG_HOME/gtrace/config.yaml",
+		},
+		&cli.StringFlag{
+			Name:    "profile",
+			EnvVars: []string{"GTRACE_PROFILE"},
+			Value:   config.DefaultProfile,
+			Usage:   "configuration profile providing the values of flags not set on the command line",
+		},
+		&cli.StringFlag{
+			Name:  "time-zone",
+			Usage: "time zone to print times in, such as UTC or Europe/London. defaults to the local time zone",
+		},
+	}
+}
+
+func queryFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "query",
+		Usage: "saved query of the configuration profile providing the filter, since and limit flags not set",
+	}
+}
+
+func configPath(c *cli.Context) (string, error) {
+	if path := c.Path("config"); path != "" {
+		return path, nil
+	}
+	return config.DefaultPath()
+}
+
+func loadConfig(c *cli.Context) (*config.Config, string, error) {
+	path, err := configPath(c)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, path, nil
+}
+
+// loadProfile loads the selected profile into the app metadata and applies its time zone. A missing profile is
+// an error only when selected explicitly.
+func loadProfile(c *cli.Context) error {
+	cfg, _, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	name := c.String("profile")
+	profile := cfg.Profile(name)
+	if profile == nil && c.IsSet("profile") && c.Args().First() != ConfigCommand.Name {
+		return fmt.Errorf("unknown profile %q (profiles: %s)", name, strings.Join(cfg.Names(), ", "))
+	}
+	c.App.Metadata = map[string]interface{}{profileMetadata: profile}
+
+	tz := c.String("time-zone")
+	if tz == "" && profile != nil {
+		tz = profile.TimeZone
+	}
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("time zone: %w", err)
+		}
+		time.Local = loc
+	}
+	return nil
+}
+
+// withProfile makes the commands take the values of flags not set on the command line from the profile.
+func withProfile(commands []*cli.Command) {
+	for _, cmd := range commands {
+		cmd.Before = applyProfile
+		withProfile(cmd.Subcommands)
+	}
+}
+
+func applyProfile(c *cli.Context) error {
+	profile, _ := c.App.Metadata[profileMetadata].(*config.Profile)
+	if c.IsSet("query") {
+		if profile == nil || profile.Queries[c.String("query")] == nil {
+			return fmt.Errorf("unknown query %q in profile %q", c.String("query"), c.String("profile"))
+		}
+	}
+	if profile == nil {
+		return nil
+	}
+
+	values := map[string][]string{
+		"format":        nonEmpty(profile.Format),
+		"service-label": profile.ServiceRules,
+	}
+	if q := profile.Queries[c.String("query")]; c.IsSet("query") {
+		values["filter"] = q.Filter
+		if q.Since > 0 {
+			values["since"] = []string{q.Since.String()}
+		}
+		if q.Limit > 0 {
+			values["limit"] = []string{strconv.Itoa(q.Limit)}
+		}
+	}
+
+	for _, flag := range c.Command.Flags {
+		name := flag.Names()[0]
+		if c.IsSet(name) {
+			continue
+		}
+		flagValues := values[name]
+		if name == "project" {
+			if !profileProjects(c.Command) {
+				continue
+			}
+			// Commands reading a single project take the first one.
+			flagValues = profile.Projects
+			if _, ok := flag.(*cli.StringSliceFlag); !ok && len(flagValues) > 1 {
+				flagValues = flagValues[:1]
+			}
+		}
+		for _, value := range flagValues {
+			if err := c.Set(name, value); err != nil {
+				return fmt.Errorf("profile %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// profileProjects reports whether the command takes the projects of the profile, which only the commands listing
+// or fetching traces from the API do. Commands writing traces or reading the archive only take projects set on the
+// command line.
+func profileProjects(cmd *cli.Command) bool {
+	switch cmd {
+	case ListCommand, GetCommand, FetchAllCommand, CompareCommand, OutliersCommand, AssertCommand, TUICommand,
+		WatchCommand, ServeCommand, URLCommand:
+		return true
+	}
+	return false
+}
+
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+var configGetAction = func(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected a single key")
+	}
+	cfg, _, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	profile := cfg.Profile(c.String("profile"))
+	if profile == nil {
+		return fmt.Errorf("unknown profile %q", c.String("profile"))
+	}
+	values, err := profile.Get(c.Args().First())
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		fmt.Println(v)
+	}
+	return nil
+}
+
+var configSetAction = func(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("missing key")
+	}
+	cfg, path, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	name := c.String("profile")
+	profile := cfg.Profile(name)
+	if profile == nil {
+		profile = &config.Profile{}
+	}
+	if err = profile.Set(c.Args().First(), c.Args().Tail()); err != nil {
+		return err
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]*config.Profile)
+	}
+	cfg.Profiles[name] = profile
+	return cfg.Save(path)
+}
+
+var configListAction = func(c *cli.Context) error {
+	cfg, path, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	names := cfg.Names()
+	if c.IsSet("profile") {
+		if cfg.Profile(c.String("profile")) == nil {
+			return fmt.Errorf("unknown profile %q", c.String("profile"))
+		}
+		names = []string{c.String("profile")}
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "# %s\n", path)
+	for _, name := range names {
+		profile := cfg.Profile(name)
+		for _, key := range profile.Keys() {
+			values, _ := profile.Get(key)
+			fmt.Printf("%s.%s=%s\n", name, key, strings.Join(values, ","))
+		}
+	}
+	return nil
+}
+
+var ConfigCommand = &cli.Command{
+	Name:  "config",
+	Usage: "Get and set the values of the configuration profiles",
+	Description: "The configuration file, $XDG_CONFIG_HOME/gtrace/config.yaml by default, holds named profiles of default " +
+		"projects, output format, time zone, service-derivation rules and saved queries. The profile is selected with " +
+		"--profile or GTRACE_PROFILE, and defaults to \"default\". Flags set on the command line always take precedence " +
+		"over the profile. Projects are only taken by the commands listing or fetching traces from Cloud Trace, not " +
+		"by put or archive.\n\nKeys: projects, format, time-zone, service-rules, queries.<name>.filter, " +
+		"queries.<name>.since and queries.<name>.limit. Saved queries are used with --query <name>.",
+	UsageText: "gtrace [--profile <name>] config <get|set|list>",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "get",
+			Usage:     "Print the values of a key, one per line",
+			UsageText: "gtrace [--profile <name>] config get <key>",
+			Action:    configGetAction,
+		},
+		{
+			Name:      "set",
+			Usage:     "Set the values of a key, or unset it when no value is given",
+			UsageText: "gtrace [--profile <name>] config set <key> [value]...",
+			Action:    configSetAction,
+		},
+		{
+			Name:      "list",
+			Aliases:   []string{"ls"},
+			Usage:     "Print the keys of every profile, or of the selected one, as <profile>.<key>=<values>",
+			UsageText: "gtrace [--profile <name>] config list",
+			Action:    configListAction,
+		},
+	},
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"flag"
+	"slices"
+	"testing"
+
+	"github.com/moshebe/gtrace/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+// projects returns the values of the project flag of the command, whether it takes one project or several.
+func projects(c *cli.Context) []string {
+	for _, f := range c.Command.Flags {
+		if f.Names()[0] != "project" {
+			continue
+		}
+		if _, ok := f.(*cli.StringSliceFlag); ok {
+			return stringSlice(c, "project")
+		}
+		if project := c.String("project"); project != "" {
+			return []string{project}
+		}
+	}
+	return nil
+}
+
+func TestApplyProfile(t *testing.T) {
+	profile := &config.Profile{Projects: []string{"a", "b"}}
+	archiveCommand := func(name string) *cli.Command {
+		for _, cmd := range ArchiveCommand.Subcommands {
+			if cmd.Name == name {
+				return cmd
+			}
+		}
+		t.Fatalf("unknown archive command %q", name)
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		cmd      *cli.Command
+		args     []string
+		want     []string
+		wantList bool
+	}{
+		{name: "list takes the first project", cmd: ListCommand, want: []string{"a"}},
+		{name: "get takes all the projects", cmd: GetCommand, want: []string{"a", "b"}},
+		{name: "flag wins", cmd: ListCommand, args: []string{"--project", "c"}, want: []string{"c"}},
+		{name: "put", cmd: PutCommand},
+		{name: "archive add", cmd: archiveCommand("add")},
+		{name: "archive search", cmd: archiveCommand("search")},
+		{name: "archive get", cmd: archiveCommand("get"), args: []string{"abc"}},
+		{name: "assert lists from the profile project", cmd: AssertCommand, args: []string{"--rules", "rules"}, want: []string{"a"}, wantList: true},
+		{name: "assert reads the given files", cmd: AssertCommand, args: []string{"--rules", "rules", "trace.json"}, want: []string{"a"}},
+		{name: "outliers reads stdin", cmd: OutliersCommand, args: []string{"-"}, want: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flag.NewFlagSet(tt.cmd.Name, flag.ContinueOnError)
+			for _, f := range tt.cmd.Flags {
+				if err := f.Apply(set); err != nil {
+					t.Fatalf("failed to apply flag: %v", err)
+				}
+			}
+			if err := set.Parse(tt.args); err != nil {
+				t.Fatalf("failed to parse args: %v", err)
+			}
+			app := &cli.App{Metadata: map[string]interface{}{profileMetadata: profile}}
+			c := cli.NewContext(app, set, nil)
+			c.Command = tt.cmd
+
+			if err := applyProfile(c); err != nil {
+				t.Fatalf("applyProfile() error: %v", err)
+			}
+			if got := projects(c); !slices.Equal(got, tt.want) {
+				t.Fatalf("project=%v want: %v", got, tt.want)
+			}
+			if tt.cmd == AssertCommand || tt.cmd == OutliersCommand {
+				if list := listsTraces(c); list != tt.wantList {
+					t.Fatalf("listsTraces()=%v want: %v", list, tt.wantList)
+				}
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package cli
 import (
 	"fmt"
 	"os"
+	"slices"
 	"sort"
 	"time"
 
+	"github.com/moshebe/gtrace/pkg/model"
 	"github.com/moshebe/gtrace/pkg/span"
 	"github.com/urfave/cli/v2"
 	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
@@ -31,20 +33,62 @@ var durationAction = func(c *cli.Context) error {
 		return fmt.Errorf("unmarshal trace: %w", err)
 	}
 
-	trace.Spans = span.FilterMinDuration(trace.Spans, min)
+	group, err := groupBy(c, "")
+	if err != nil {
+		return err
+	}
+	if group != "" && !c.Bool("summary") {
+		return fmt.Errorf("--group-by requires --summary")
+	}
+	rules := errorRules(c)
+	resolver, err := serviceResolver(c)
+	if err != nil {
+		return err
+	}
+
+	converted := model.FromV1(&trace)
+	adjustSkew(c, converted)
+	// services are resolved before filtering, since spans may inherit their service from filtered out ancestors.
+	services := resolver.Services(converted)
+	spans := make([]*model.Span, 0, len(converted.Spans))
+	for _, s := range converted.Spans {
+		if s.Timed() && s.Duration() >= min {
+			spans = append(spans, s)
+		}
+	}
 
 	if c.Bool("sort") {
-		sort.Slice(trace.Spans, func(i, j int) bool {
-			return span.Duration(trace.Spans[i]) > span.Duration(trace.Spans[j])
+		sort.Slice(spans, func(i, j int) bool {
+			return spans[i].Duration() > spans[j].Duration()
 		})
 	}
 
 	if !c.Bool("summary") {
+		trace.Spans = make([]*cloudtrace.TraceSpan, 0, len(spans))
+		for _, s := range spans {
+			trace.Spans = append(trace.Spans, s.V1())
+		}
 		return printTraceJSON(os.Stdout, &trace)
 	}
 
-	for _, s := range trace.Spans {
-		fmt.Println(span.DurationSummary(s))
+	if group != "" {
+		key := func(s *model.Span) string { return s.Name }
+		if group == "service" {
+			key = func(s *model.Span) string { return serviceName(services[s.ID]) }
+		}
+		for _, g := range span.GroupDurations(spans, key, rules) {
+			fmt.Printf("%s - %d spans took %s in total (p50 %s, max %s), %d errors\n",
+				g.Group, g.Stats.Count, g.Stats.Total, g.Stats.P50, g.Stats.Max, g.Errors)
+		}
+		return nil
+	}
+
+	for _, s := range spans {
+		summary := span.DurationSummary(s.V1())
+		if rules.IsError(s) {
+			summary += " - error"
+		}
+		fmt.Println(summary)
 	}
 	return nil
 }
@@ -56,7 +100,7 @@ var DurationCommand = &cli.Command{
 		"performance or latency issues within a specific trace",
 	UsageText: "gtrace duration [command options]",
 	Action:    durationAction,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.PathFlag{
 			Name:    "file",
 			Aliases: []string{"f"},
@@ -78,5 +122,5 @@ var DurationCommand = &cli.Command{
 			Value: true,
 			Usage: "sort the results in descending order by span duration",
 		},
-	},
+	}, slices.Concat(errorFlags(), skewFlags(), serviceFlags())...),
 }
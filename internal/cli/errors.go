@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/urfave/cli/v2"
+)
+
+type errorResult struct {
+	ProjectID string            `json:"projectId,omitempty"`
+	TraceID   string            `json:"traceId"`
+	SpanID    uint64            `json:"spanId"`
+	Name      string            `json:"name"`
+	Duration  time.Duration     `json:"duration"`
+	Path      []string          `json:"path"`
+	Labels    map[string]string `json:"labels"`
+}
+
+func errorFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  "min-http-status",
+			Value: span.DefaultErrorRules.MinHTTPStatus,
+			Usage: "lowest HTTP status code of a failed span. 0 ignores HTTP status codes",
+		},
+		&cli.StringSliceFlag{
+			Name:  "error-label",
+			Usage: "labels marking a failed span when set. values can be set multiple times or separated by comma (default: " + strings.Join(span.DefaultErrorRules.Labels, ", ") + ")",
+		},
+		&cli.StringSliceFlag{
+			Name:  "status-label",
+			Usage: "labels holding a gRPC status code, any code but OK marks a failed span. values can be set multiple times or separated by comma (default: " + strings.Join(span.DefaultErrorRules.StatusLabels, ", ") + ")",
+		},
+	}
+}
+
+func errorRules(c *cli.Context) span.ErrorRules {
+	rules := span.DefaultErrorRules
+	if c.IsSet("min-http-status") {
+		rules.MinHTTPStatus = c.Int("min-http-status")
+	}
+	if c.IsSet("error-label") {
+		rules.Labels = stringSlice(c, "error-label")
+	}
+	if c.IsSet("status-label") {
+		rules.StatusLabels = stringSlice(c, "status-label")
+	}
+	return rules
+}
+
+var errorsAction = func(c *cli.Context) error {
+	traces, err := readModelTraces(c.String("file"))
+	if err != nil {
+		return err
+	}
+	rules := errorRules(c)
+
+	results := []errorResult{}
+	for _, trace := range traces {
+		adjustSkew(c, trace)
+		trace.DFS(func(s *model.Span) bool {
+			reasons := rules.Reasons(s)
+			if len(reasons) == 0 {
+				return true
+			}
+			ancestors := trace.Ancestors(s.ID)
+			path := make([]string, 0, len(ancestors))
+			for i := len(ancestors) - 1; i >= 0; i-- {
+				path = append(path, ancestors[i].Name)
+			}
+			results = append(results, errorResult{
+				ProjectID: trace.ProjectID,
+				TraceID:   trace.TraceID,
+				SpanID:    s.ID,
+				Name:      s.Name,
+				Duration:  s.Duration(),
+				Path:      path,
+				Labels:    reasons,
+			})
+			return true
+		})
+	}
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(results, "", "\t")
+		} else {
+			output, err = json.Marshal(results)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		for _, result := range results {
+			fmt.Printf("%s %s (%d) - took %s\n", result.TraceID, result.Name, result.SpanID, result.Duration)
+			if len(result.Path) > 0 {
+				fmt.Printf("  path: %s\n", strings.Join(result.Path, " > "))
+			}
+			for _, key := range sortedKeys(result.Labels) {
+				fmt.Printf("  %s: %s\n", key, result.Labels[key])
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
+}
+
+var ErrorsCommand = &cli.Command{
+	Name:  "errors",
+	Usage: "List the failed spans of traces",
+	Description: "Find the spans that failed, with the chain of their ancestors from the root and the labels telling " +
+		"they failed. By default a span fails if its HTTP status code is 500 or more, if it has an error, error " +
+		"message, error name or stack trace label, or if it carries a non-OK gRPC status code, by number or by name.",
+	UsageText: "gtrace errors [command options]",
+	Action:    errorsAction,
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Value:   "-",
+			Usage:   "input file path. '-' means stdin",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	}, append(errorFlags(), skewFlags()...)...),
+}
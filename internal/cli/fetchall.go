@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	fetchQueryFile    = "query.json"
+	fetchManifestFile = "manifest.ndjson"
+)
+
+// fetchQuery is the listing request of an archive directory. It is persisted with absolute times so an interrupted
+// fetch can be resumed against the very same window.
+type fetchQuery struct {
+	Project string    `json:"project"`
+	Filter  []string  `json:"filter,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Limit   int32     `json:"limit,omitempty"`
+}
+
+// manifestEntry describes a single trace file stored in an archive directory.
+type manifestEntry struct {
+	TraceID  string    `json:"traceId"`
+	Project  string    `json:"project"`
+	Root     string    `json:"root"`
+	Start    time.Time `json:"start"`
+	Duration string    `json:"duration"`
+	Spans    int       `json:"spans"`
+	File     string    `json:"file"`
+}
+
+func newManifestEntry(trace *cloudtrace.Trace, file string) manifestEntry {
+	start, end := span.Interval(trace.GetSpans())
+	return manifestEntry{
+		TraceID:  trace.GetTraceId(),
+		Project:  trace.GetProjectId(),
+		Root:     span.Root(trace.GetSpans()).GetName(),
+		Start:    start,
+		Duration: end.Sub(start).String(),
+		Spans:    len(trace.GetSpans()),
+		File:     file,
+	}
+}
+
+// readManifest returns the ids of the traces already stored in dir. A truncated last line, left by an interrupted
+// run, is ignored.
+func readManifest(dir string) (map[string]struct{}, error) {
+	seen := make(map[string]struct{})
+	f, err := os.Open(filepath.Join(dir, fetchManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return seen, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open manifest: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry manifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		seen[entry.TraceID] = struct{}{}
+	}
+	return seen, scanner.Err()
+}
+
+// differences returns the flags set on the command line that disagree with the stored query.
+func (q *fetchQuery) differences(c *cli.Context) []string {
+	var flags []string
+	if c.IsSet("project") && c.String("project") != q.Project {
+		flags = append(flags, "--project")
+	}
+	if c.IsSet("filter") && !slices.Equal(c.StringSlice("filter"), q.Filter) {
+		flags = append(flags, "--filter")
+	}
+	if c.IsSet("limit") && int32(c.Int("limit")) != q.Limit {
+		flags = append(flags, "--limit")
+	}
+	if c.IsSet("since") && !c.IsSet("start") && c.Duration("since") != q.End.Sub(q.Start) {
+		flags = append(flags, "--since")
+	}
+	if ts := c.Timestamp("start"); ts != nil && !ts.Equal(q.Start) {
+		flags = append(flags, "--start")
+	}
+	if ts := c.Timestamp("end"); ts != nil && !ts.Equal(q.End) {
+		flags = append(flags, "--end")
+	}
+	return flags
+}
+
+// loadFetchQuery returns the query stored in dir, or builds a new one from the command flags and stores it.
+func loadFetchQuery(c *cli.Context, dir string) (*fetchQuery, bool, error) {
+	path := filepath.Join(dir, fetchQueryFile)
+	in, err := os.ReadFile(path)
+	if err == nil {
+		var query fetchQuery
+		if err = json.Unmarshal(in, &query); err != nil {
+			return nil, false, fmt.Errorf("unmarshal query: %w", err)
+		}
+		if flags := query.differences(c); len(flags) > 0 {
+			return nil, false, fmt.Errorf("directory %q holds a fetch with a different %s, see %s",
+				dir, strings.Join(flags, ", "), path)
+		}
+		return &query, true, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("read query: %w", err)
+	}
+
+	if !c.IsSet("project") {
+		return nil, false, fmt.Errorf("missing project")
+	}
+	query := &fetchQuery{
+		Project: c.String("project"),
+		Filter:  c.StringSlice("filter"),
+		End:     time.Now(),
+		Limit:   int32(c.Int("limit")),
+	}
+	query.Start = query.End.Add(-c.Duration("since"))
+	if ts := c.Timestamp("start"); ts != nil {
+		query.Start = *ts
+	}
+	if ts := c.Timestamp("end"); ts != nil {
+		query.End = *ts
+	}
+
+	out, err := json.MarshalIndent(query, "", "\t")
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal query: %w", err)
+	}
+	if err = os.WriteFile(path, out, 0o644); err != nil {
+		return nil, false, fmt.Errorf("write query: %w", err)
+	}
+	return query, false, nil
+}
+
+var fetchAllAction = func(c *cli.Context) error {
+	dir := c.String("dir")
+	if dir == "" {
+		return fmt.Errorf("missing output directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+
+	query, resumed, err := loadFetchQuery(c, dir)
+	if err != nil {
+		return err
+	}
+	seen, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+	if resumed && !c.Bool("quiet") {
+		fmt.Fprintf(os.Stderr, "resuming fetch into %s (%d traces already fetched)\n", dir, len(seen))
+	}
+
+	manifest, err := os.OpenFile(filepath.Join(dir, fetchManifestFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open manifest: %w", err)
+	}
+	defer func() { _ = manifest.Close() }()
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+	defer stop()
+
+	trc, err := tracer.NewTracer(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = trc.Close() }()
+
+	var bar *progress
+	if !c.Bool("quiet") {
+		bar = newProgress(os.Stderr, "fetched traces", 0)
+		defer bar.Finish()
+	}
+
+	opts := []tracer.ListOption{
+		tracer.WithStartTime(query.Start),
+		tracer.WithEndTime(query.End),
+		tracer.WithFilter(query.Filter...),
+	}
+	err = trc.Walk(ctx, query.Project, query.Limit, func(trace *cloudtrace.Trace) error {
+		defer bar.Inc()
+		if _, found := seen[trace.GetTraceId()]; found {
+			return nil
+		}
+		span.Sort(trace.Spans)
+
+		out, err := protojson.Marshal(trace)
+		if err != nil {
+			return fmt.Errorf("marshal trace %q: %w", trace.GetTraceId(), err)
+		}
+		file := trace.GetTraceId() + ".json"
+		if err = os.WriteFile(filepath.Join(dir, file), out, 0o644); err != nil {
+			return err
+		}
+
+		entry, err := json.Marshal(newManifestEntry(trace, file))
+		if err != nil {
+			return fmt.Errorf("marshal manifest entry: %w", err)
+		}
+		if _, err = fmt.Fprintln(manifest, string(entry)); err != nil {
+			return fmt.Errorf("write manifest: %w", err)
+		}
+		seen[trace.GetTraceId()] = struct{}{}
+		return nil
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("list traces: %w", err)
+	}
+	return nil
+}
+
+var FetchAllCommand = &cli.Command{
+	Name:   "fetch-all",
+	Action: fetchAllAction,
+	Usage:  "Download all the traces matching the given conditions into a local directory",
+	Description: "List the complete traces matching the conditions and write each one as <trace-id>.json into the directory, " +
+		"along with a manifest.ndjson index. The query is stored in the directory on the first run, so re-running the " +
+		"command on the same directory resumes an interrupted fetch and skips the traces already in the manifest. " +
+		"Flags set when resuming must match the stored query.",
+	UsageText: "gtrace fetch-all [command options]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "the Google Cloud project ID to use for this invocation",
+		},
+		&cli.PathFlag{
+			Name:    "dir",
+			Aliases: []string{"d"},
+			Usage:   "output directory for the trace files and the manifest",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Value: 1000,
+			Usage: "maximum number of traces to fetch. 0 means no limit",
+		},
+		&cli.DurationFlag{
+			Name:  "since",
+			Value: time.Hour,
+			Usage: "time duration to inspect since now",
+		},
+		&cli.StringSliceFlag{
+			Name:    "filter",
+			Aliases: []string{"f"},
+			Usage:   "filter traces according to Cloud Trace API syntax. can be set multiple times. See: https://cloud.google.com/trace/docs/trace-filters#filter_syntax",
+		},
+		queryFlag(),
+		&cli.TimestampFlag{
+			Name:   "start",
+			Layout: "2006-01-02T15:04:05",
+			Usage:  "start of the time interval (inclusive) during which the trace data was collected from the application",
+		},
+		&cli.TimestampFlag{
+			Name:   "end",
+			Layout: "2006-01-02T15:04:05",
+			Usage:  "end of the time interval (inclusive) during which the trace data was collected from the application",
+		},
+		&cli.BoolFlag{
+			Name:    "quiet",
+			Aliases: []string{"q"},
+			Usage:   "do not report fetching progress to stderr",
+		},
+	},
+}
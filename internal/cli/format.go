@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 
+	"github.com/moshebe/gtrace/pkg/model"
 	"github.com/moshebe/gtrace/pkg/span"
 	"github.com/urfave/cli/v2"
 	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
@@ -20,6 +21,21 @@ func printTraceJSON(w io.Writer, trace *cloudtrace.Trace) error {
 	return err
 }
 
+// printTraces prints a single trace as is and multiple traces as newline delimited JSON.
+func printTraces(w io.Writer, traces []*cloudtrace.Trace) error {
+	for _, trace := range traces {
+		if err := printTraceJSON(w, trace); err != nil {
+			return err
+		}
+		if len(traces) > 1 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 var formatAction = func(c *cli.Context) error {
 	format := c.String("template")
 	file := c.String("file")
@@ -35,7 +51,42 @@ var formatAction = func(c *cli.Context) error {
 		return fmt.Errorf("unmarshal trace: %w", err)
 	}
 
-	return span.Format(trace.Spans, format, os.Stdout)
+	adjustSkewV1(c, &trace)
+
+	group, err := groupBy(c, "")
+	if err != nil {
+		return err
+	}
+	resolver, err := serviceResolver(c)
+	if err != nil {
+		return err
+	}
+	services := resolver.Services(model.FromV1(&trace))
+	if group == "" {
+		return span.FormatServices(trace.Spans, services, format, os.Stdout)
+	}
+
+	// groups are printed in the order of their first span, each under a header line.
+	key := func(s *cloudtrace.TraceSpan) string { return s.GetName() }
+	if group == "service" {
+		key = func(s *cloudtrace.TraceSpan) string { return serviceName(services[s.GetSpanId()]) }
+	}
+	var order []string
+	grouped := make(map[string][]*cloudtrace.TraceSpan)
+	for _, s := range trace.Spans {
+		g := key(s)
+		if _, found := grouped[g]; !found {
+			order = append(order, g)
+		}
+		grouped[g] = append(grouped[g], s)
+	}
+	for _, g := range order {
+		fmt.Printf("%s (%d spans)\n", g, len(grouped[g]))
+		if err = span.FormatServices(grouped[g], services, format, os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 var FormatCommand = &cli.Command{
@@ -44,7 +95,7 @@ var FormatCommand = &cli.Command{
 	Description: "See more information at: https://cloud.google.com/trace/docs/reference/v1/rest/v1/projects.traces#TraceSpan",
 	UsageText:   "gtrace format [command options]",
 	Action:      formatAction,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.PathFlag{
 			Name:    "file",
 			Aliases: []string{"f"},
@@ -54,7 +105,7 @@ var FormatCommand = &cli.Command{
 		&cli.StringFlag{
 			Name:  "template",
 			Value: "{{ .Name }}  ({{ .Start }}  -  took {{ .Duration }})\n{{ if .Labels }}\t{{ .Labels }}\n{{ end }}",
-			Usage: "templated pattern to format each span record base on TraceSpan properties\n\t",
+			Usage: "templated pattern to format each span record base on TraceSpan properties, along with .Service\n\t",
 		},
-	},
+	}, append(skewFlags(), serviceFlags()...)...),
 }
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/urfave/cli/v2"
+)
+
+type gapsResult struct {
+	ProjectID string `json:"projectId,omitempty"`
+	TraceID   string `json:"traceId"`
+	span.SpanGaps
+}
+
+var gapsAction = func(c *cli.Context) error {
+	traces, err := readModelTraces(c.String("file"))
+	if err != nil {
+		return err
+	}
+
+	results := []gapsResult{}
+	for _, trace := range traces {
+		adjustSkew(c, trace)
+		for _, g := range span.Gaps(trace, c.Duration("threshold")) {
+			results = append(results, gapsResult{ProjectID: trace.ProjectID, TraceID: trace.TraceID, SpanGaps: g})
+		}
+	}
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(results, "", "\t")
+		} else {
+			output, err = json.Marshal(results)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		for _, r := range results {
+			fmt.Printf("%s %s (%d) - took %s, %s not covered by children\n", r.TraceID, r.Name, r.SpanID, r.Duration, r.Uncovered)
+			for _, g := range r.Gaps {
+				fmt.Printf("  +%s - +%s: %s\n", g.Offset, g.Offset+g.Duration, g.Duration)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
+}
+
+var GapsCommand = &cli.Command{
+	Name:  "gaps",
+	Usage: "Find the time of spans not covered by their children",
+	Description: "Report, per span with children, the intervals not covered by any child lasting at least " +
+		"--threshold, with offsets relative to the span start. Large gaps usually mean missing instrumentation " +
+		"or lock contention.",
+	UsageText: "gtrace gaps [command options]",
+	Action:    gapsAction,
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Value:   "-",
+			Usage:   "input file path. '-' means stdin",
+		},
+		&cli.DurationFlag{
+			Name:  "threshold",
+			Value: 10 * time.Millisecond,
+			Usage: "minimal duration of a reported gap",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	}, skewFlags()...),
+}
@@ -1,13 +1,22 @@
 package cli
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/moshebe/gtrace/pkg/span"
 	"github.com/moshebe/gtrace/pkg/tracer"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
@@ -15,6 +24,24 @@ var getAction = func(c *cli.Context) error {
 	ids := c.Args().Slice()
 	projects := stringSlice(c, "project")
 
+	if c.IsSet("ids-from") {
+		in, err := read(c.String("ids-from"))
+		if err != nil {
+			return err
+		}
+		parsed, err := parseTraceIDs(in)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, parsed...)
+	}
+	ids = unique(ids)
+	for _, id := range ids {
+		if !traceIDPattern.MatchString(id) {
+			return fmt.Errorf("invalid trace id %q, expected 32 hex characters", id)
+		}
+	}
+
 	if len(projects) == 0 {
 		return fmt.Errorf("missing project")
 	}
@@ -22,10 +49,14 @@ var getAction = func(c *cli.Context) error {
 		return fmt.Errorf("missing trace id")
 	}
 
+	if c.Bool("separate") {
+		return getSeparate(c, projects, ids)
+	}
+
 	ctx, cancel := context.WithTimeout(c.Context, time.Minute)
 	defer cancel()
 
-	trc, err := tracer.NewTracer(ctx)
+	trc, err := tracer.NewTracer(ctx, tracerOptions(c)...)
 	if err != nil {
 		return err
 	}
@@ -52,13 +83,137 @@ var getAction = func(c *cli.Context) error {
 	return nil
 }
 
+// getSeparate fetches every trace id on its own and writes each result either as a single NDJSON line to stdout
+// or as a dedicated file in the output directory.
+func getSeparate(c *cli.Context, projects []string, ids []string) error {
+	outDir := c.String("out-dir")
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return fmt.Errorf("create output directory: %w", err)
+		}
+	}
+
+	indent := ""
+	if c.Bool("pretty") && outDir != "" {
+		indent = "\t"
+	}
+
+	trc, err := tracer.NewTracer(c.Context, tracerOptions(c)...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = trc.Close() }()
+
+	var bar *progress
+	if !c.Bool("quiet") {
+		bar = newProgress(os.Stderr, "fetching traces", len(ids))
+		defer bar.Finish()
+	}
+
+	var (
+		mu     sync.Mutex
+		failed []string
+	)
+	g, ctx := errgroup.WithContext(c.Context)
+	g.SetLimit(max(c.Int("parallel"), 1))
+	for _, id := range ids {
+		g.Go(func() error {
+			defer bar.Inc()
+
+			reqCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+
+			trace, err := trc.MultiGet(reqCtx, projects, []string{id})
+			if err != nil {
+				mu.Lock()
+				failed = append(failed, id)
+				mu.Unlock()
+				return nil
+			}
+			span.Sort(trace.Spans)
+
+			out, err := protojson.MarshalOptions{Indent: indent}.Marshal(trace)
+			if err != nil {
+				return fmt.Errorf("marshal trace %q: %w", id, err)
+			}
+
+			if outDir != "" {
+				return os.WriteFile(filepath.Join(outDir, id+".json"), out, 0o644)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			_, err = fmt.Fprintln(os.Stdout, string(out))
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("no spans found for %d trace(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+var (
+	traceIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+	// listHeaderPattern matches the root span lines of the text output of the list command.
+	listHeaderPattern = regexp.MustCompile(`\(\d+ traces\)$`)
+)
+
+// parseTraceIDs extracts trace ids either from the JSON output of the list command or from plain text
+// containing whitespace separated ids, such as the text output of the list command. Lines starting with '#' are
+// ignored.
+func parseTraceIDs(in []byte) ([]string, error) {
+	var results []listResult
+	if err := json.Unmarshal(in, &results); err == nil {
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.Traces...)
+		}
+		return ids, nil
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || listHeaderPattern.MatchString(text) {
+			continue
+		}
+		for _, id := range strings.Fields(text) {
+			if !traceIDPattern.MatchString(id) {
+				return nil, fmt.Errorf("invalid trace id %q at line %d", id, line)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, scanner.Err()
+}
+
+func unique(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	results := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, found := seen[v]; found {
+			continue
+		}
+		seen[v] = struct{}{}
+		results = append(results, v)
+	}
+	return results
+}
+
 var GetCommand = &cli.Command{
-	Name:        "get",
-	Action:      getAction,
-	Usage:       "Get a specific trace by id from one or more projects",
-	Description: "Retrieve the trace information from the given project(s), aggregate the results and sort the spans by their start time.",
-	UsageText:   "gtrace get [command options] <trace-id>",
-	Flags: []cli.Flag{
+	Name:   "get",
+	Action: getAction,
+	Usage:  "Get a specific trace by id from one or more projects",
+	Description: "Retrieve the trace information from the given project(s), aggregate the results and sort the spans by their start time.\n" +
+		"Use --separate to fetch every trace id on its own and emit one trace per id (NDJSON to stdout or one file per trace in --out-dir).",
+	UsageText: "gtrace get [command options] <trace-id>...",
+	Flags: append([]cli.Flag{
 		&cli.StringSliceFlag{
 			Name:    "project",
 			Aliases: []string{"p"},
@@ -68,5 +223,27 @@ var GetCommand = &cli.Command{
 			Name:  "pretty",
 			Usage: "prettify JSON output",
 		},
-	},
+		&cli.PathFlag{
+			Name:  "ids-from",
+			Usage: "read trace ids from a file, one per line or the JSON output of the list command. '-' means stdin",
+		},
+		&cli.BoolFlag{
+			Name:  "separate",
+			Usage: "output each trace on its own instead of merging all ids into a single trace",
+		},
+		&cli.PathFlag{
+			Name:  "out-dir",
+			Usage: "directory to write one <trace-id>.json file per trace into. only applies with --separate",
+		},
+		&cli.IntFlag{
+			Name:  "parallel",
+			Value: 4,
+			Usage: "maximum number of traces fetched concurrently. only applies with --separate",
+		},
+		&cli.BoolFlag{
+			Name:    "quiet",
+			Aliases: []string{"q"},
+			Usage:   "do not report fetching progress to stderr",
+		},
+	}, cacheFlags()...),
 }
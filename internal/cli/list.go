@@ -22,7 +22,12 @@ var listAction = func(c *cli.Context) error {
 	}
 	var limit int32 = 10
 
-	opts := []tracer.ListOption{tracer.WithOnlyRootSpanView(), tracer.WithLimit(10)}
+	opts := []tracer.ListOption{tracer.WithLimit(10)}
+
+	// clustering by shape needs every span, the root span is enough to group by name.
+	if !c.Bool("cluster") {
+		opts = append(opts, tracer.WithOnlyRootSpanView())
+	}
 
 	if c.IsSet("limit") {
 		limit = int32(c.Int("limit"))
@@ -51,7 +56,7 @@ var listAction = func(c *cli.Context) error {
 	}
 
 	ctx := context.Background()
-	trc, err := tracer.NewTracer(ctx)
+	trc, err := tracer.NewTracer(ctx, tracerOptions(c)...)
 	if err != nil {
 		return err
 	}
@@ -62,6 +67,10 @@ var listAction = func(c *cli.Context) error {
 		return fmt.Errorf("list traces: %w", err)
 	}
 
+	if c.Bool("cluster") {
+		return printClusters(c, span.Clusters(toModel(traces), c.Int("repetition-cap")))
+	}
+
 	rootSpans := span.ListRootSpans(traces)
 
 	results := make([]listResult, 0, len(rootSpans))
@@ -104,7 +113,7 @@ var ListCommand = &cli.Command{
 	Action:    listAction,
 	Usage:     "Query traces from a project according to the given conditions",
 	UsageText: "gtrace list [command options]",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "project",
 			Aliases: []string{"p"},
@@ -124,6 +133,7 @@ var ListCommand = &cli.Command{
 			Aliases: []string{"f"},
 			Usage:   "filter traces according to Cloud Trace API syntax. can be set multiple times. See: https://cloud.google.com/trace/docs/trace-filters#filter_syntax",
 		},
+		queryFlag(),
 		&cli.TimestampFlag{
 			Name:   "start",
 			Layout: "2006-01-02T15:04:05",
@@ -134,6 +144,15 @@ var ListCommand = &cli.Command{
 			Layout: "2006-01-02T15:04:05",
 			Usage:  "end of the time interval (inclusive) during which the trace data was collected from the application",
 		},
+		&cli.BoolFlag{
+			Name:  "cluster",
+			Usage: "group the traces by root span name and span tree shape, with per-cluster latency stats",
+		},
+		&cli.IntFlag{
+			Name:  "repetition-cap",
+			Value: span.DefaultRepetitionCap,
+			Usage: "number of repetitions of identical sibling spans above which shapes no longer differ when clustering",
+		},
 		&cli.BoolFlag{
 			Name:  "pretty",
 			Usage: "prettify JSON output",
@@ -143,5 +162,35 @@ var ListCommand = &cli.Command{
 			Value: "json",
 			Usage: "output format: json or text",
 		},
-	},
+	}, cacheFlags()...),
+}
+
+func printClusters(c *cli.Context, clusters []span.Cluster) error {
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		var err error
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(clusters, "", "\t")
+		} else {
+			output, err = json.Marshal(clusters)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		for _, cluster := range clusters {
+			fmt.Printf("%s %s (%d traces, p50 %s, p95 %s, max %s)\n", cluster.ID, cluster.Endpoint, cluster.Stats.Count,
+				cluster.Stats.P50, cluster.Stats.P95, cluster.Stats.Max)
+			fmt.Printf("  shape: %s\n", cluster.Signature)
+			for _, traceID := range cluster.Traces {
+				fmt.Printf("  %s\n", traceID)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
 }
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+)
+
+// outlierTraces reads the traces of the given paths, or lists the traces of the project if no path is given. Without
+// paths nor project, traces are read from stdin.
+func outlierTraces(c *cli.Context) ([]*cloudtrace.Trace, error) {
+	if !listsTraces(c) {
+		paths := c.Args().Slice()
+		if len(paths) == 0 {
+			paths = []string{"-"}
+		}
+		return collectTraces(paths)
+	}
+
+	ctx := context.Background()
+	trc, err := tracer.NewTracer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = trc.Close() }()
+
+	opts := []tracer.ListOption{tracer.WithSince(c.Duration("since")), tracer.WithFilter(c.StringSlice("filter")...)}
+	traces, err := trc.List(ctx, c.String("project"), int32(c.Int("limit")), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("list traces: %w", err)
+	}
+	return traces, nil
+}
+
+var outliersAction = func(c *cli.Context) error {
+	traces, err := outlierTraces(c)
+	if err != nil {
+		return err
+	}
+
+	results := []span.Outlier{}
+	for _, o := range span.Outliers(toModel(traces)) {
+		if o.Score < c.Float64("min-score") {
+			continue
+		}
+		if top := c.Int("top"); top > 0 && len(results) >= top {
+			break
+		}
+		results = append(results, o)
+	}
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(results, "", "\t")
+		} else {
+			output, err = json.Marshal(results)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "TRACE\tENDPOINT\tDURATION\tSCORE\tREASONS")
+		for _, o := range results {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%s\n", o.TraceID, o.Endpoint, o.Duration, o.Score, strings.Join(o.Reasons, "; "))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
+}
+
+var OutliersCommand = &cli.Command{
+	Name:  "outliers",
+	Usage: "Find the most unusual traces of each endpoint",
+	Description: "Build a baseline per endpoint, the root span name, from the median number of spans and fan-out " +
+		"per span name and the mean duration of its traces, then score every trace by its structural difference, " +
+		"missing or extra spans, unusual repetitions and fan-outs, plus its latency z-score. The most unusual traces " +
+		"are printed with the reasons. Traces are read from the given files and directories, '-' being stdin, or " +
+		"listed from --project, or the profile project, when none is given, and read from stdin otherwise.",
+	UsageText: "gtrace outliers [command options] [trace files or directories...]",
+	Action:    outliersAction,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "the Google Cloud project ID to list traces from",
+		},
+		&cli.DurationFlag{
+			Name:  "since",
+			Value: time.Hour,
+			Usage: "time duration to inspect since now when listing traces",
+		},
+		&cli.StringSliceFlag{
+			Name:    "filter",
+			Aliases: []string{"f"},
+			Usage:   "filter the listed traces according to Cloud Trace API syntax. can be set multiple times. See: https://cloud.google.com/trace/docs/trace-filters#filter_syntax",
+		},
+		queryFlag(),
+		&cli.IntFlag{
+			Name:  "limit",
+			Value: 500,
+			Usage: "maximum number of traces to list",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Value: 10,
+			Usage: "maximum number of traces to print. 0 prints all of them",
+		},
+		&cli.Float64Flag{
+			Name:  "min-score",
+			Usage: "minimal score of a printed trace",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	},
+}
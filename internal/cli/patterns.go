@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/urfave/cli/v2"
+)
+
+type patternResult struct {
+	ProjectID string `json:"projectId,omitempty"`
+	TraceID   string `json:"traceId"`
+	span.Repetition
+}
+
+var patternsAction = func(c *cli.Context) error {
+	traces, err := readModelTraces(c.String("file"))
+	if err != nil {
+		return err
+	}
+
+	results := []patternResult{}
+	for _, trace := range traces {
+		adjustSkew(c, trace)
+		for _, r := range span.Repetitions(trace, c.Int("threshold"), stringSlice(c, "label")) {
+			results = append(results, patternResult{ProjectID: trace.ProjectID, TraceID: trace.TraceID, Repetition: r})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Savings > results[j].Savings })
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(results, "", "\t")
+		} else {
+			output, err = json.Marshal(results)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "TRACE\tPARENT\tSPAN\tCOUNT\tEXECUTION\tTOTAL\tWALL\tSAVINGS\tLABELS")
+		for _, r := range results {
+			_, _ = fmt.Fprintf(w, "%s\t%s (%d)\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", r.TraceID, r.ParentName, r.ParentID, r.Name,
+				r.Count, r.Execution, r.Total, r.Wall, r.Savings, formatLabels(r.Labels))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range sortedKeys(labels) {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", key, labels[key]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+var PatternsCommand = &cli.Command{
+	Name:  "patterns",
+	Usage: "Find repeated sibling spans such as N+1 queries",
+	Description: "Find the sibling spans sharing a name repeated at least --threshold times under the same parent, " +
+		"the typical N+1 query of a handler issuing the same database call in a loop. With --label, siblings are also " +
+		"grouped by the given label values, with quoted strings and numbers ignored so that queries differing only " +
+		"by their parameters are alike. Each pattern tells whether the spans ran sequentially or in parallel and " +
+		"estimates the time saved by batching them into a single call lasting as long as the slowest one.",
+	UsageText: "gtrace patterns [command options]",
+	Action:    patternsAction,
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Value:   "-",
+			Usage:   "input file path. '-' means stdin",
+		},
+		&cli.IntFlag{
+			Name:  "threshold",
+			Value: span.DefaultRepetitionThreshold,
+			Usage: "minimal number of repeated sibling spans",
+		},
+		&cli.StringSliceFlag{
+			Name:  "label",
+			Usage: "labels whose similar values group the siblings, such as /sql/query. values can be set multiple times or separated by comma",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	}, skewFlags()...),
+}
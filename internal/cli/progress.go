@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// progress reports the number of completed items, out of the total if known, on a single self-overwriting line.
+type progress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	label string
+	total int
+	done  int
+}
+
+func newProgress(w io.Writer, label string, total int) *progress {
+	p := &progress{w: w, label: label, total: total}
+	p.print()
+	return p
+}
+
+func (p *progress) Inc() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.print()
+}
+
+func (p *progress) Finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = fmt.Fprintln(p.w)
+}
+
+func (p *progress) print() {
+	if p.total <= 0 {
+		_, _ = fmt.Fprintf(p.w, "\r%s %d", p.label, p.done)
+		return
+	}
+	_, _ = fmt.Fprintf(p.w, "\r%s %d/%d", p.label, p.done, p.total)
+}
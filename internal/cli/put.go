@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	tracev2 "cloud.google.com/go/trace/apiv2/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// newTraceID returns a random 128-bit trace id in the hex format used by Cloud Trace.
+func newTraceID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generate trace id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// traceIDs returns a function mapping trace ids, either to themselves or to new random ids if rewrite is set.
+// The same input id is always mapped to the same new id.
+func traceIDs(rewrite bool) func(string) (string, error) {
+	ids := make(map[string]string)
+	return func(id string) (string, error) {
+		if !rewrite {
+			return id, nil
+		}
+		if mapped, found := ids[id]; found {
+			return mapped, nil
+		}
+		mapped, err := newTraceID()
+		if err != nil {
+			return "", err
+		}
+		ids[id] = mapped
+		return mapped, nil
+	}
+}
+
+// readV2Spans reads v2 spans in the format of a BatchWriteSpans request and groups them by project.
+func readV2Spans(c *cli.Context) (map[string][]*tracev2.Span, []string, error) {
+	in, err := read(c.String("file"))
+	if err != nil {
+		return nil, nil, err
+	}
+	var req tracev2.BatchWriteSpansRequest
+	if err = protojson.Unmarshal(in, &req); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal spans: %w", err)
+	}
+
+	mapID := traceIDs(c.Bool("rewrite-ids"))
+	byProject := make(map[string][]*tracev2.Span)
+	var projects []string
+	uploaded := make(map[string]struct{})
+	for _, s := range req.GetSpans() {
+		project, traceID, spanID, err := model.ParseV2Name(s.GetName())
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.IsSet("project") {
+			project = c.String("project")
+		}
+		uploaded[traceID] = struct{}{}
+		if traceID, err = mapID(traceID); err != nil {
+			return nil, nil, err
+		}
+		s.Name = model.V2Name(project, traceID, spanID)
+
+		if _, found := byProject[project]; !found {
+			projects = append(projects, project)
+		}
+		byProject[project] = append(byProject[project], s)
+	}
+
+	// links follow the traces they point to only if those are uploaded too, links to other traces are kept.
+	for _, s := range req.GetSpans() {
+		for _, link := range s.GetLinks().GetLink() {
+			if _, found := uploaded[link.GetTraceId()]; !found {
+				continue
+			}
+			if link.TraceId, err = mapID(link.GetTraceId()); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return byProject, projects, nil
+}
+
+// readV1Traces reads v1 traces and groups them by project.
+func readV1Traces(c *cli.Context) (map[string][]*cloudtrace.Trace, []string, error) {
+	traces, err := readTraces(c.String("file"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapID := traceIDs(c.Bool("rewrite-ids"))
+	byProject := make(map[string][]*cloudtrace.Trace)
+	var projects []string
+	for _, trace := range traces {
+		project := c.String("project")
+		if project == "" {
+			project = trace.GetProjectId()
+		}
+		if project == "" {
+			return nil, nil, fmt.Errorf("missing project for trace %q", trace.GetTraceId())
+		}
+		// get merges the spans of several projects and trace ids into a single trace joining them with '+'.
+		if strings.Contains(project, "+") {
+			return nil, nil, fmt.Errorf("trace %q merges the spans of projects %q, set --project to write it to a single project",
+				trace.GetTraceId(), project)
+		}
+		trace.ProjectId = project
+		if trace.TraceId, err = mapID(trace.GetTraceId()); err != nil {
+			return nil, nil, err
+		}
+		if strings.Contains(trace.GetTraceId(), "+") {
+			return nil, nil, fmt.Errorf("trace %q merges several trace ids, set --rewrite-ids to write it under a new id",
+				trace.GetTraceId())
+		}
+		if _, found := byProject[project]; !found {
+			projects = append(projects, project)
+		}
+		byProject[project] = append(byProject[project], trace)
+	}
+	return byProject, projects, nil
+}
+
+var putAction = func(c *cli.Context) error {
+	api := c.String("api")
+	if api != "v1" && api != "v2" {
+		return fmt.Errorf("unsupported api: %s (supported apis: v1, v2)", api)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, time.Minute)
+	defer cancel()
+
+	trc, err := tracer.NewTracer(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = trc.Close() }()
+
+	switch format := c.String("input-format"); format {
+	case "v1":
+		byProject, projects, err := readV1Traces(c)
+		if err != nil {
+			return err
+		}
+		if len(projects) == 0 {
+			return fmt.Errorf("no traces found")
+		}
+		for _, project := range projects {
+			traces := byProject[project]
+			if api == "v1" {
+				err = trc.Patch(ctx, project, traces...)
+			} else {
+				var spans []*tracev2.Span
+				for _, trace := range traces {
+					spans = append(spans, model.FromV1(trace).V2()...)
+				}
+				err = trc.Write(ctx, project, spans...)
+			}
+			if err != nil {
+				return fmt.Errorf("write traces: %w", err)
+			}
+			for _, trace := range traces {
+				fmt.Println(trace.GetTraceId())
+			}
+		}
+	case "v2":
+		if api != "v2" {
+			return fmt.Errorf("v2 spans can only be written with --api v2")
+		}
+		byProject, projects, err := readV2Spans(c)
+		if err != nil {
+			return err
+		}
+		if len(projects) == 0 {
+			return fmt.Errorf("no spans found")
+		}
+		for _, project := range projects {
+			if err = trc.Write(ctx, project, byProject[project]...); err != nil {
+				return fmt.Errorf("write spans: %w", err)
+			}
+			var ids []string
+			for _, s := range byProject[project] {
+				_, traceID, _, _ := model.ParseV2Name(s.GetName())
+				ids = append(ids, traceID)
+			}
+			for _, id := range unique(ids) {
+				fmt.Println(id)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported input format: %s (supported formats: v1, v2)", format)
+	}
+	return nil
+}
+
+var PutCommand = &cli.Command{
+	Name:  "put",
+	Usage: "Upload traces to a project",
+	Description: "Send the traces of the input file, a single trace or newline delimited traces, to Cloud Trace and print " +
+		"their ids. Spans of an already existing trace id are merged into it, use --rewrite-ids to upload copies instead.\n" +
+		"With --api v2 the spans are written through the v2 API, which carries span status, annotations, message events " +
+		"and links. v1 traces are converted on the fly, deriving the status from their labels, while v2 input " +
+		"(--input-format v2, a BatchWriteSpans request body) is written as is.",
+	UsageText: "gtrace put [command options]",
+	Action:    putAction,
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Value:   "-",
+			Usage:   "input file path. '-' means stdin",
+		},
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "the Google Cloud project ID to upload into. defaults to the project of each trace",
+		},
+		&cli.BoolFlag{
+			Name:  "rewrite-ids",
+			Usage: "assign a new random id to every trace to avoid colliding with existing traces",
+		},
+		&cli.StringFlag{
+			Name:  "api",
+			Value: "v1",
+			Usage: "Cloud Trace API version to write with: v1 or v2",
+		},
+		&cli.StringFlag{
+			Name:  "input-format",
+			Value: "v1",
+			Usage: "input format: v1 traces or v2 spans",
+		},
+	},
+}
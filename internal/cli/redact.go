@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/filter"
+	"github.com/moshebe/gtrace/pkg/redact"
+	"github.com/urfave/cli/v2"
+)
+
+var redactAction = func(c *cli.Context) error {
+	opts := []redact.Option{redact.WithTimeShift(c.Duration("shift"))}
+	if key := c.String("key"); key != "" {
+		opts = append(opts, redact.WithKey([]byte(key)))
+	}
+	if patterns := c.StringSlice("drop-label"); len(patterns) > 0 {
+		f, err := filter.New(patterns, true)
+		if err != nil {
+			return fmt.Errorf("drop label filter: %w", err)
+		}
+		opts = append(opts, redact.WithDropLabels(f))
+	}
+	if patterns := c.StringSlice("hash-label"); len(patterns) > 0 {
+		f, err := filter.New(patterns, true)
+		if err != nil {
+			return fmt.Errorf("hash label filter: %w", err)
+		}
+		opts = append(opts, redact.WithHashLabels(f))
+	}
+	if c.Bool("keep-url-query") {
+		opts = append(opts, redact.WithURLQuery())
+	}
+
+	r, err := redact.New(opts...)
+	if err != nil {
+		return err
+	}
+
+	traces, err := readTraces(c.String("file"))
+	if err != nil {
+		return err
+	}
+	results := make([]*cloudtrace.Trace, 0, len(traces))
+	for _, trace := range traces {
+		results = append(results, r.Trace(trace))
+	}
+	return printTraces(os.Stdout, results)
+}
+
+var RedactCommand = &cli.Command{
+	Name:  "redact",
+	Usage: "Anonymize traces before sharing them",
+	Description: "Remap trace and span ids while keeping the parent links intact, drop or hash label values by key, " +
+		"scrub the query string of URL labels and shift timestamps. Ids and hashes are derived from --key, so " +
+		"the same key redacts related traces consistently. Without a key a random one is used.",
+	UsageText: "gtrace redact [command options]",
+	Action:    redactAction,
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Value:   "-",
+			Usage:   "input file path. '-' means stdin",
+		},
+		&cli.StringFlag{
+			Name:    "key",
+			Usage:   "secret key of the ids and values hashing",
+			EnvVars: []string{"GTRACE_REDACT_KEY"},
+		},
+		&cli.StringSliceFlag{
+			Name:  "drop-label",
+			Usage: "regex of label keys to remove. can be set multiple times",
+		},
+		&cli.StringSliceFlag{
+			Name:  "hash-label",
+			Usage: "regex of label keys whose values are replaced by their hash. can be set multiple times",
+		},
+		&cli.BoolFlag{
+			Name:  "keep-url-query",
+			Usage: "keep the query string of URL labels",
+		},
+		&cli.DurationFlag{
+			Name:  "shift",
+			Usage: "duration to shift all the timestamps by, e.g. -720h",
+		},
+	},
+}
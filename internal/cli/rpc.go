@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/urfave/cli/v2"
+)
+
+var rpcAction = func(c *cli.Context) error {
+	traces, err := readModelTraces(c.String("file"))
+	if err != nil {
+		return err
+	}
+
+	group, err := groupBy(c, "name")
+	if err != nil {
+		return err
+	}
+	resolver, err := serviceResolver(c)
+	if err != nil {
+		return err
+	}
+
+	var pairs []span.RPCPair
+	services := make(map[*model.Span]string)
+	for _, trace := range traces {
+		adjustSkew(c, trace)
+		tracePairs := span.RPCPairs(trace)
+		if group == "service" {
+			byID := resolver.Services(trace)
+			for _, p := range tracePairs {
+				services[p.Client] = serviceName(byID[p.Client.ID])
+				services[p.Server] = serviceName(byID[p.Server.ID])
+			}
+		}
+		pairs = append(pairs, tracePairs...)
+	}
+
+	edgeFunc := span.SpanNameEdge
+	if group == "service" {
+		edgeFunc = func(p span.RPCPair) (string, string) {
+			return services[p.Client], services[p.Server]
+		}
+	}
+	edges := span.RPCEdges(pairs, edgeFunc)
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(edges, "", "\t")
+		} else {
+			output, err = json.Marshal(edges)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CALLER\tCALLEE\tCALLS\tCLIENT AVG\tSERVER AVG\tOVERHEAD AVG\tOVERHEAD P95\tOVERHEAD MAX")
+		for _, e := range edges {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", e.Caller, e.Callee, e.Overhead.Count,
+				e.Client.Mean, e.Server.Mean, e.Overhead.Mean, e.Overhead.P95, e.Overhead.Max)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	return nil
+}
+
+var RPCCommand = &cli.Command{
+	Name:  "rpc",
+	Usage: "Break down RPC latency into server time and network overhead",
+	Description: "Pair every RPC_CLIENT span with its RPC_SERVER child and report, per caller and callee, the time " +
+		"spent outside the server span (client duration minus server duration), which accounts for the network, " +
+		"load balancers and queueing. Negative overheads usually mean clock skew, see --adjust-skew. Callers and callees " +
+		"are the client and server span names, or their services with --group-by service.",
+	UsageText: "gtrace rpc [command options]",
+	Action:    rpcAction,
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Value:   "-",
+			Usage:   "input file path. '-' means stdin",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	}, append(skewFlags(), serviceFlags()...)...),
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/moshebe/gtrace/internal/server"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+)
+
+var serveAction = func(c *cli.Context) error {
+	addr := c.String("addr")
+	if c.String("token") == "" && !server.Loopback(addr) {
+		return fmt.Errorf("--token is required to listen on %s beyond localhost", addr)
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+	defer stop()
+
+	trc, err := tracer.NewTracer(ctx, tracerOptions(c)...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = trc.Close() }()
+
+	opts := []server.Option{server.WithProject(c.String("project")), server.WithToken(c.String("token"))}
+	if !c.Bool("no-archive") {
+		store, err := openArchive(c)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, server.WithArchive(store))
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           server.New(trc, opts...),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	_, _ = fmt.Fprintf(os.Stderr, "serving on http://%s\n", srv.Addr)
+	if err = srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
+
+var ServeCommand = &cli.Command{
+	Name:  "serve",
+	Usage: "Serve a web UI and a JSON API to browse traces",
+	Description: "Serve a self-contained web UI searching the traces of Cloud Trace, with the credentials of the " +
+		"running user, and of the local archive, and rendering their waterfall, span tree and labels. The JSON API " +
+		"lists traces (/api/traces), searches the archive (/api/archive), gets a trace (/api/traces/{project}/{id}) " +
+		"and analyzes it (/api/traces/{project}/{id}/{errors,validate,critical-path,gaps,patterns,concurrency}), " +
+		"with ?source=archive for archived traces. Anyone reaching the address browses traces as the running user, " +
+		"so --token is required when listening beyond localhost; the UI then needs to be opened with ?token=<token>. " +
+		"Without --token, only requests addressed to localhost are served.",
+	UsageText: "gtrace serve [--addr localhost:8080] [command options]",
+	Action:    serveAction,
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "addr",
+			Value: "localhost:8080",
+			Usage: "address to listen on, such as :8080 to accept remote connections, which requires --token",
+		},
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "the default Google Cloud project ID to search",
+		},
+		&cli.StringFlag{
+			Name:    "token",
+			EnvVars: []string{"GTRACE_SERVE_TOKEN"},
+			Usage:   "token required by the API, as a bearer token or a token query parameter",
+		},
+		&cli.BoolFlag{
+			Name:  "no-archive",
+			Usage: "do not serve the local archive",
+		},
+		archiveDirFlag(),
+	}, cacheFlags()...),
+}
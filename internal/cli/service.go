@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/urfave/cli/v2"
+)
+
+const unknownService = "(unknown)"
+
+func serviceFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "group-by",
+			Usage: "group the results by span 'name' or 'service'",
+		},
+		&cli.StringSliceFlag{
+			Name: "service-label",
+			Usage: "rule deriving the service of a span: a label key, or key=regex capturing the service from the label value. " +
+				"can be set multiple times, the first matching rule wins. defaults to well-known service labels",
+		},
+	}
+}
+
+// groupBy returns the validated grouping of the results, or def if not set.
+func groupBy(c *cli.Context, def string) (string, error) {
+	group := c.String("group-by")
+	if group == "" {
+		group = def
+	}
+	if group != "" && group != "name" && group != "service" {
+		return "", fmt.Errorf("unsupported grouping: %s (supported groupings: name, service)", group)
+	}
+	return group, nil
+}
+
+func serviceResolver(c *cli.Context) (*span.ServiceResolver, error) {
+	var rules []span.ServiceRule
+	for _, value := range c.StringSlice("service-label") {
+		rule, err := span.ParseServiceRule(value)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return span.NewServiceResolver(rules...), nil
+}
+
+func serviceName(service string) string {
+	if service == "" {
+		return unknownService
+	}
+	return service
+}
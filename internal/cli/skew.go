@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/urfave/cli/v2"
+)
+
+func skewFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "adjust-skew",
+			Usage: "correct clock skew between hosts before the analysis and report the applied offsets to stderr",
+		},
+		&cli.StringSliceFlag{
+			Name:  "host-label",
+			Usage: "labels identifying the host of a span for the clock skew correction, in order of preference. values can be set multiple times or separated by comma",
+		},
+	}
+}
+
+// adjustSkew corrects the clock skew of the trace spans if requested and reports the applied offsets.
+func adjustSkew(c *cli.Context, trace *model.Trace) {
+	if !c.Bool("adjust-skew") {
+		return
+	}
+	for _, adj := range span.AdjustSkew(trace, stringSlice(c, "host-label")) {
+		host := adj.Host
+		if host == "" {
+			host = "unknown host"
+		}
+		fmt.Fprintf(os.Stderr, "%s: shifted %s (%d) on %s and its local descendants by %s\n",
+			trace.TraceID, adj.Name, adj.SpanID, host, adj.Offset)
+	}
+}
+
+// adjustSkewV1 corrects the clock skew of a v1 trace if requested, for commands printing v1 spans.
+func adjustSkewV1(c *cli.Context, trace *cloudtrace.Trace) {
+	if !c.Bool("adjust-skew") {
+		return
+	}
+	converted := model.FromV1(trace)
+	adjustSkew(c, converted)
+	trace.Spans = converted.V1().GetSpans()
+}
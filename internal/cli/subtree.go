@@ -38,8 +38,8 @@ var subtreeAction = func(c *cli.Context) error {
 var SubtreeCommand = &cli.Command{
 	Name:  "subtree",
 	Usage: "Extract span and all its children for a given trace",
-	Description: "Iterating the trace spans according to the original ordering and opt-out all spans that " +
-		"are not descendants the given root span",
+	Description: "Index the trace spans by their parent links, whatever their ordering, and opt-out all spans that " +
+		"are not descendants the given root span. The original ordering of the spans is kept",
 	UsageText: "gtrace subtree [command options]",
 	Action:    subtreeAction,
 	Flags: []cli.Flag{
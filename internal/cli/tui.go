@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/moshebe/gtrace/internal/tui"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+)
+
+var tuiAction = func(c *cli.Context) error {
+	if !c.IsSet("project") {
+		return fmt.Errorf("missing project")
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+	defer stop()
+
+	trc, err := tracer.NewTracer(ctx, tracerOptions(c)...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = trc.Close() }()
+
+	opts := []tracer.ListOption{
+		tracer.WithOnlyRootSpanView(),
+		tracer.WithSince(c.Duration("since")),
+		tracer.WithFilter(c.StringSlice("filter")...),
+	}
+	return tui.New(trc, c.String("project"), int32(c.Int("limit")), opts...).Run(ctx)
+}
+
+var TUICommand = &cli.Command{
+	Name:  "tui",
+	Usage: "Browse the traces of a project in an interactive terminal UI",
+	Description: "List the traces of a project in the left pane. Selecting one fetches it and shows its span tree " +
+		"with waterfall bars, failed spans in red and the critical path in yellow, and the labels of the selected " +
+		"span in the detail pane.\n\nKeys: enter opens a trace or collapses a span, tab switches panes, / filters " +
+		"the traces by root span name, c jumps to the next span of the critical path, y copies the console URL " +
+		"of the trace, e exports the trace to <trace id>.json, r reloads the traces and q quits.",
+	UsageText: "gtrace tui --project <project> [command options]",
+	Action:    tuiAction,
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "the Google Cloud project ID to use for this invocation",
+		},
+		&cli.DurationFlag{
+			Name:  "since",
+			Value: time.Hour,
+			Usage: "time duration to inspect since now",
+		},
+		&cli.StringSliceFlag{
+			Name:    "filter",
+			Aliases: []string{"f"},
+			Usage:   "filter traces according to Cloud Trace API syntax. can be set multiple times. See: https://cloud.google.com/trace/docs/trace-filters#filter_syntax",
+		},
+		queryFlag(),
+		&cli.IntFlag{
+			Name:  "limit",
+			Value: 100,
+			Usage: "maximum number of traces to list",
+		},
+	}, cacheFlags()...),
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 
+	"github.com/moshebe/gtrace/pkg/tracer"
 	"github.com/urfave/cli/v2"
 )
 
@@ -12,11 +13,7 @@ var urlAction = func(c *cli.Context) error {
 		return fmt.Errorf("missing trace id")
 	}
 
-	projectPath := ""
-	if c.IsSet("project") {
-		projectPath += "&project=" + c.String("project")
-	}
-	fmt.Printf("https://console.cloud.google.com/traces/list?tid=%s%s\n", id, projectPath)
+	fmt.Println(tracer.ConsoleURL(c.String("project"), id))
 	return nil
 }
 
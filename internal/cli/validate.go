@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/urfave/cli/v2"
+)
+
+type validateResult struct {
+	ProjectID string       `json:"projectId,omitempty"`
+	TraceID   string       `json:"traceId"`
+	Issues    []span.Issue `json:"issues"`
+}
+
+var validateAction = func(c *cli.Context) error {
+	ignored := make(map[span.IssueKind]struct{})
+	for _, kind := range stringSlice(c, "ignore") {
+		ignored[span.IssueKind(kind)] = struct{}{}
+	}
+
+	traces, err := readModelTraces(c.String("file"))
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	results := make([]validateResult, 0, len(traces))
+	for _, trace := range traces {
+		adjustSkew(c, trace)
+		result := validateResult{ProjectID: trace.ProjectID, TraceID: trace.TraceID, Issues: []span.Issue{}}
+		for _, issue := range span.Validate(trace) {
+			if _, found := ignored[issue.Kind]; found {
+				continue
+			}
+			result.Issues = append(result.Issues, issue)
+		}
+		count += len(result.Issues)
+		results = append(results, result)
+	}
+
+	format := c.String("format")
+	switch format {
+	case "json":
+		var output []byte
+		if c.Bool("pretty") {
+			output, err = json.MarshalIndent(results, "", "\t")
+		} else {
+			output, err = json.Marshal(results)
+		}
+		if err != nil {
+			return fmt.Errorf("marshal results: %w", err)
+		}
+		fmt.Println(string(output))
+	case "text":
+		for _, result := range results {
+			fmt.Printf("%s (%d issues)\n", result.TraceID, len(result.Issues))
+			for _, issue := range result.Issues {
+				fmt.Printf("  %s: %s (%d) %s\n", issue.Kind, issue.Name, issue.SpanID, issue.Message)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+
+	if count > 0 {
+		return cli.Exit(fmt.Sprintf("found %d issues", count), 1)
+	}
+	return nil
+}
+
+var ValidateCommand = &cli.Command{
+	Name:  "validate",
+	Usage: "Report structural problems of traces",
+	Description: "Check the traces for orphan spans, multiple roots, parent cycles, duplicate span ids, missing " +
+		"timestamps, non-positive durations and children starting before or ending after their parent. " +
+		"Exits with a non-zero code if any issue is found.\n\nIssue kinds: " + issueKinds(),
+	UsageText: "gtrace validate [command options]",
+	Action:    validateAction,
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Value:   "-",
+			Usage:   "input file path. '-' means stdin",
+		},
+		&cli.StringSliceFlag{
+			Name:  "ignore",
+			Usage: "issue kinds to ignore. values can be set multiple times or separated by comma",
+		},
+		&cli.BoolFlag{
+			Name:  "pretty",
+			Usage: "prettify JSON output",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json or text",
+		},
+	}, skewFlags()...),
+}
+
+func issueKinds() string {
+	kinds := make([]string, 0, len(span.IssueKinds))
+	for _, kind := range span.IssueKinds {
+		kinds = append(kinds, string(kind))
+	}
+	return strings.Join(kinds, ", ")
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryable reports whether listing failed because of quota or transient errors, which are retried with backoff.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.ResourceExhausted, codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+var watchAction = func(c *cli.Context) error {
+	if !c.IsSet("project") {
+		return fmt.Errorf("missing project")
+	}
+	format := c.String("format")
+	if format != "json" && format != "text" {
+		return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+	}
+	interval, maxBackoff, overlap := c.Duration("interval"), c.Duration("max-backoff"), c.Duration("overlap")
+	slowerThan, onlyErrors := c.Duration("slower-than"), c.Bool("errors")
+
+	filters := c.StringSlice("filter")
+	if slowerThan > 0 {
+		filters = append(filters, fmt.Sprintf("latency:%dms", slowerThan.Milliseconds()))
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+	defer stop()
+
+	trc, err := tracer.NewTracer(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = trc.Close() }()
+
+	// seen keeps the end of the poll window the printed traces were listed in, until the window start moves past
+	// it. Traces without an end time are kept as long as the others.
+	seen := make(map[string]time.Time)
+	start := time.Now().Add(-c.Duration("since"))
+	delay := interval
+	for {
+		end := time.Now()
+		from := start.Add(-overlap)
+		opts := []tracer.ListOption{tracer.WithStartTime(from), tracer.WithEndTime(end), tracer.WithFilter(filters...)}
+		err = trc.Walk(ctx, c.String("project"), 0, func(trace *cloudtrace.Trace) error {
+			if _, found := seen[trace.GetTraceId()]; found {
+				return nil
+			}
+			traceStart, traceEnd := span.Interval(trace.GetSpans())
+			errorCount := span.DefaultErrorRules.CountErrors(model.FromV1(trace).Spans)
+			// skipped traces are checked again while listed, since late spans may make them slower or failed.
+			if traceEnd.Sub(traceStart) < slowerThan || (onlyErrors && errorCount == 0) {
+				return nil
+			}
+			seen[trace.GetTraceId()] = end
+			if format == "json" {
+				if err := printTraceJSON(os.Stdout, trace); err != nil {
+					return err
+				}
+				fmt.Println()
+				return nil
+			}
+			root := span.Root(trace.GetSpans())
+			fmt.Printf("%s %s %s took %s (%d spans, %d errors)\n", traceStart.Local().Format(time.RFC3339), trace.GetTraceId(),
+				root.GetName(), traceEnd.Sub(traceStart), len(trace.GetSpans()), errorCount)
+			return nil
+		}, opts...)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil && retryable(err):
+			delay = min(delay*2, maxBackoff)
+			_, _ = fmt.Fprintf(os.Stderr, "list traces: %v, retrying in %s\n", err, delay)
+		case err != nil:
+			return fmt.Errorf("list traces: %w", err)
+		default:
+			delay = interval
+			start = end
+			for id, listed := range seen {
+				if listed.Before(start.Add(-overlap)) {
+					delete(seen, id)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+var WatchCommand = &cli.Command{
+	Name:    "watch",
+	Aliases: []string{"tail"},
+	Usage:   "Stream new traces of a project as they arrive",
+	Description: "Poll the traces of a project every --interval with a moving start time and print the ones not " +
+		"seen yet, until interrupted. Every poll lists again the last --overlap to catch traces ingested late. " +
+		"Quota and transient errors are retried with an exponential backoff up to --max-backoff.",
+	UsageText: "gtrace watch --project <project> [command options]",
+	Action:    watchAction,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "the Google Cloud project ID to use for this invocation",
+		},
+		&cli.StringSliceFlag{
+			Name:    "filter",
+			Aliases: []string{"f"},
+			Usage:   "filter traces according to Cloud Trace API syntax. can be set multiple times. See: https://cloud.google.com/trace/docs/trace-filters#filter_syntax",
+		},
+		queryFlag(),
+		&cli.DurationFlag{
+			Name:  "since",
+			Value: time.Minute,
+			Usage: "time duration to look back on the first poll",
+		},
+		&cli.DurationFlag{
+			Name:  "interval",
+			Value: 10 * time.Second,
+			Usage: "time between polls",
+		},
+		&cli.DurationFlag{
+			Name:  "overlap",
+			Value: time.Minute,
+			Usage: "time duration before the previous poll listed again to catch late traces",
+		},
+		&cli.DurationFlag{
+			Name:  "max-backoff",
+			Value: 5 * time.Minute,
+			Usage: "maximum time between polls when retrying quota and transient errors",
+		},
+		&cli.DurationFlag{
+			Name:  "slower-than",
+			Usage: "only print traces lasting at least the given duration",
+		},
+		&cli.BoolFlag{
+			Name:  "errors",
+			Usage: "only print traces with failed spans",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: json, printing a trace per line, or text",
+		},
+	},
+}
@@ -0,0 +1,431 @@
+// Package server serves a web UI and a JSON API to browse traces from Cloud Trace and from a local archive.
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/archive"
+	"github.com/moshebe/gtrace/pkg/filter"
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+//go:embed ui.html
+var ui []byte
+
+const (
+	sourceCloud   = "cloud"
+	sourceArchive = "archive"
+)
+
+// Server handles the UI and the API. API routes:
+//
+//	GET /api/traces?project=&since=&filter=&limit=           list traces from Cloud Trace
+//	GET /api/archive?project=&root=&label=key=regex&min-duration=&max-duration=
+//	                                                          search the archive
+//	GET /api/traces/{project}/{id}?source=cloud|archive       get a trace
+//	GET /api/traces/{project}/{id}/{analysis}?source=         analyze a trace: errors, validate, critical-path
+//	                                                          (group-by=name|service, service-label), gaps
+//	                                                          (threshold), patterns (threshold, label) or
+//	                                                          concurrency
+type Server struct {
+	tracer  *tracer.Tracer
+	archive *archive.Archive
+	project string
+	token   string
+	mux     *http.ServeMux
+}
+
+type Option func(s *Server)
+
+// WithArchive serves the traces of the archive too.
+func WithArchive(a *archive.Archive) Option {
+	return func(s *Server) {
+		s.archive = a
+	}
+}
+
+// WithProject sets the project listed when a request sets none.
+func WithProject(project string) Option {
+	return func(s *Server) {
+		s.project = project
+	}
+}
+
+// WithToken requires requests to carry the token, as a bearer token or a token query parameter.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+func New(trc *tracer.Tracer, opts ...Option) *Server {
+	s := &Server{tracer: trc, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(ui)
+	})
+	s.mux.HandleFunc("GET /api/config", s.handleConfig)
+	s.mux.HandleFunc("GET /api/traces", s.handleList)
+	s.mux.HandleFunc("GET /api/archive", s.handleSearch)
+	s.mux.HandleFunc("GET /api/traces/{project}/{id}", s.handleGet)
+	s.mux.HandleFunc("GET /api/traces/{project}/{id}/{analysis}", s.handleAnalysis)
+	return s
+}
+
+// ServeHTTP serves the request. Without a token, only requests addressed to a loopback host are served, so that
+// a page of another site rebinding its domain name to the local address cannot call the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token == "" && !Loopback(r.Host) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed without a token", r.Host))
+		return
+	}
+	if s.token != "" && r.URL.Path != "/" && !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Loopback reports whether the host, with or without a port, names the local host: localhost or a loopback IP.
+func Loopback(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// summary describes a trace in search results.
+type summary struct {
+	Project  string        `json:"project"`
+	TraceID  string        `json:"traceId"`
+	Root     string        `json:"root"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Source   string        `json:"source"`
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// errorCode maps API errors to HTTP status codes.
+func errorCode(err error) int {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadGateway
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{"project": s.project, "archive": s.archive != nil})
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	project := q.Get("project")
+	if project == "" {
+		project = s.project
+	}
+	if project == "" {
+		writeError(w, http.StatusBadRequest, errors.New("missing project"))
+		return
+	}
+	since, err := durationParam(q.Get("since"), time.Hour)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := 50
+	if value := q.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+			return
+		}
+	}
+
+	opts := []tracer.ListOption{tracer.WithOnlyRootSpanView(), tracer.WithSince(since), tracer.WithFilter(q["filter"]...)}
+	traces, err := s.tracer.List(r.Context(), project, int32(limit), opts...)
+	if err != nil {
+		writeError(w, errorCode(err), fmt.Errorf("list traces: %w", err))
+		return
+	}
+	results := make([]summary, 0, len(traces))
+	for _, t := range traces {
+		start, end := span.Interval(t.GetSpans())
+		results = append(results, summary{
+			Project:  project,
+			TraceID:  t.GetTraceId(),
+			Root:     span.Root(t.GetSpans()).GetName(),
+			Start:    start,
+			Duration: end.Sub(start),
+			Source:   sourceCloud,
+		})
+	}
+	writeJSON(w, results)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if s.archive == nil {
+		writeError(w, http.StatusNotFound, errors.New("no archive"))
+		return
+	}
+	query, err := archiveQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	records, err := s.archive.Search(query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	results := make([]summary, 0, len(records))
+	for _, rec := range records {
+		results = append(results, summary{
+			Project:  rec.Project,
+			TraceID:  rec.TraceID,
+			Root:     rec.Root,
+			Start:    rec.Start,
+			Duration: rec.Duration(),
+			Source:   sourceArchive,
+		})
+	}
+	writeJSON(w, results)
+}
+
+func archiveQuery(r *http.Request) (*archive.Query, error) {
+	q := r.URL.Query()
+	query := &archive.Query{Project: q.Get("project")}
+	var err error
+	if query.MinDuration, err = durationParam(q.Get("min-duration"), 0); err != nil {
+		return nil, err
+	}
+	if query.MaxDuration, err = durationParam(q.Get("max-duration"), 0); err != nil {
+		return nil, err
+	}
+	if roots := q["root"]; len(roots) > 0 {
+		if query.Root, err = filter.New(roots, true); err != nil {
+			return nil, fmt.Errorf("root filter: %w", err)
+		}
+	}
+
+	labels := make(map[string][]string)
+	for _, label := range q["label"] {
+		key, pattern, found := strings.Cut(label, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid label condition %q, expected key=regex", label)
+		}
+		labels[key] = append(labels[key], pattern)
+	}
+	query.Labels = make(map[string]*filter.Filter, len(labels))
+	for key, patterns := range labels {
+		if query.Labels[key], err = filter.New(patterns, true); err != nil {
+			return nil, fmt.Errorf("label %s filter: %w", key, err)
+		}
+	}
+	return query, nil
+}
+
+func durationParam(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+// trace loads the trace of the request from the archive or from Cloud Trace, as set by the source parameter.
+func (s *Server) trace(w http.ResponseWriter, r *http.Request) (*cloudtrace.Trace, bool) {
+	project, id := r.PathValue("project"), r.PathValue("id")
+	var trace *cloudtrace.Trace
+	var err error
+	switch source := r.URL.Query().Get("source"); source {
+	case sourceArchive:
+		if s.archive == nil {
+			writeError(w, http.StatusNotFound, errors.New("no archive"))
+			return nil, false
+		}
+		if trace, err = s.archive.Get(project, id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return nil, false
+		}
+	case sourceCloud, "":
+		if trace, err = s.tracer.Get(r.Context(), project, id); err != nil {
+			writeError(w, errorCode(err), fmt.Errorf("get trace: %w", err))
+			return nil, false
+		}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported source: %s (supported sources: cloud, archive)", source))
+		return nil, false
+	}
+	return trace, true
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	trace, ok := s.trace(w, r)
+	if !ok {
+		return
+	}
+	out, err := protojson.Marshal(trace)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("marshal trace: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
+type spanError struct {
+	SpanID uint64            `json:"spanId"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+}
+
+var analyses = map[string]bool{
+	"errors":        true,
+	"validate":      true,
+	"critical-path": true,
+	"gaps":          true,
+	"patterns":      true,
+	"concurrency":   true,
+}
+
+func (s *Server) handleAnalysis(w http.ResponseWriter, r *http.Request) {
+	analysis := r.PathValue("analysis")
+	if !analyses[analysis] {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unsupported analysis: %s", analysis))
+		return
+	}
+	trace, ok := s.trace(w, r)
+	if !ok {
+		return
+	}
+	converted := model.FromV1(trace)
+	q := r.URL.Query()
+
+	switch analysis {
+	case "errors":
+		results := []spanError{}
+		for _, sp := range converted.Spans {
+			if reasons := span.DefaultErrorRules.Reasons(sp); len(reasons) > 0 {
+				results = append(results, spanError{SpanID: sp.ID, Name: sp.Name, Labels: reasons})
+			}
+		}
+		writeJSON(w, results)
+	case "validate":
+		writeJSON(w, nonNil(span.Validate(converted)))
+	case "critical-path":
+		path := span.CriticalPath(converted)
+		if group := q.Get("group-by"); group != "" {
+			key, err := groupKey(converted, group, q["service-label"])
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			writeJSON(w, nonNil(span.GroupDurations(path, key, span.DefaultErrorRules)))
+			return
+		}
+		ids := []string{}
+		for _, sp := range path {
+			ids = append(ids, strconv.FormatUint(sp.ID, 10))
+		}
+		writeJSON(w, ids)
+	case "gaps":
+		threshold, err := durationParam(q.Get("threshold"), 10*time.Millisecond)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, nonNil(span.Gaps(converted, threshold)))
+	case "patterns":
+		threshold := 0
+		if value := q.Get("threshold"); value != "" {
+			var err error
+			if threshold, err = strconv.Atoi(value); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid threshold %q", value))
+				return
+			}
+		}
+		writeJSON(w, nonNil(span.Repetitions(converted, threshold, q["label"])))
+	case "concurrency":
+		writeJSON(w, nonNil(span.Concurrencies(converted)))
+	}
+}
+
+// groupKey returns the group of the spans of the trace: their name, or their service derived with the rules.
+func groupKey(trace *model.Trace, group string, rules []string) (func(*model.Span) string, error) {
+	switch group {
+	case "name":
+		return func(s *model.Span) string { return s.Name }, nil
+	case "service":
+		var parsed []span.ServiceRule
+		for _, value := range rules {
+			rule, err := span.ParseServiceRule(value)
+			if err != nil {
+				return nil, err
+			}
+			parsed = append(parsed, rule)
+		}
+		services := span.NewServiceResolver(parsed...).Services(trace)
+		return func(s *model.Span) string { return services[s.ID] }, nil
+	default:
+		return nil, fmt.Errorf("unsupported grouping: %s (supported groupings: name, service)", group)
+	}
+}
+
+// nonNil encodes empty results as an empty list rather than null.
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestToken(t *testing.T) {
+	srv := New(nil, WithToken("secret"))
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{name: "missing token", target: "/api/config", want: http.StatusUnauthorized},
+		{name: "wrong token", target: "/api/config", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "wrong query token", target: "/api/config?token=nope", want: http.StatusUnauthorized},
+		{name: "bearer token", target: "/api/config", header: "Bearer secret", want: http.StatusOK},
+		{name: "query token", target: "/api/config?token=secret", want: http.StatusOK},
+		{name: "ui without token", target: "/", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("GET %s returned %d want: %d", tt.target, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	srv := New(nil)
+
+	tests := []struct {
+		host string
+		want int
+	}{
+		{host: "localhost:8080", want: http.StatusOK},
+		{host: "127.0.0.1:8080", want: http.StatusOK},
+		{host: "[::1]:8080", want: http.StatusOK},
+		{host: "localhost", want: http.StatusOK},
+		{host: "evil.example.com:8080", want: http.StatusForbidden},
+		{host: "192.168.1.2:8080", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/config", nil)
+			r.Host = tt.host
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("GET /api/config with host %q returned %d want: %d", tt.host, w.Code, tt.want)
+			}
+		})
+	}
+}
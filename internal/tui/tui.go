@@ -0,0 +1,371 @@
+// Package tui is an interactive terminal browser of the traces of a project.
+package tui
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/gdamore/tcell/v2"
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
+	"github.com/moshebe/gtrace/pkg/tracer"
+	"github.com/rivo/tview"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	barWidth   = 24
+	treeIndent = 3
+	help       = "enter: open/collapse  tab: switch pane  /: filter  c: next critical span  y: copy URL  e: export  r: reload  q: quit"
+)
+
+// App browses the traces listed from a project: the list pane shows the traces, the tree pane the spans of the
+// selected trace with waterfall bars and the detail pane the labels of the selected span.
+type App struct {
+	tracer   *tracer.Tracer
+	project  string
+	limit    int32
+	listOpts []tracer.ListOption
+
+	app     *tview.Application
+	list    *tview.Table
+	tree    *tview.TreeView
+	details *tview.TextView
+	status  *tview.TextView
+	input   *tview.InputField
+	layout  *tview.Flex
+
+	traces  []*cloudtrace.Trace
+	visible []*cloudtrace.Trace
+	filter  string
+
+	current  *cloudtrace.Trace
+	trace    *model.Trace
+	nodes    map[uint64]*tview.TreeNode
+	critical []*model.Span
+	next     int
+}
+
+// New returns an App listing up to limit traces of the project with the given options.
+func New(trc *tracer.Tracer, project string, limit int32, opts ...tracer.ListOption) *App {
+	a := &App{
+		tracer:   trc,
+		project:  project,
+		limit:    limit,
+		listOpts: opts,
+		app:      tview.NewApplication(),
+		list:     tview.NewTable(),
+		tree:     tview.NewTreeView(),
+		details:  tview.NewTextView(),
+		status:   tview.NewTextView(),
+		input:    tview.NewInputField(),
+	}
+
+	a.list.SetSelectable(true, false).SetFixed(1, 0).SetBorder(true).SetTitle(" traces ")
+	a.list.SetSelectedFunc(func(row, _ int) {
+		if row > 0 && row <= len(a.visible) {
+			a.open(a.visible[row-1])
+		}
+	})
+
+	a.tree.SetBorder(true).SetTitle(" spans ")
+	a.tree.SetChangedFunc(a.showSpan)
+	a.tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		node.SetExpanded(!node.IsExpanded())
+	})
+
+	a.details.SetDynamicColors(true).SetBorder(true).SetTitle(" details ")
+	a.status.SetText(help)
+
+	a.input.SetLabel("filter: ")
+	a.input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			a.filter = a.input.GetText()
+			a.render()
+		}
+		a.layout.RemoveItem(a.input)
+		a.app.SetFocus(a.list)
+	})
+
+	panes := tview.NewFlex().
+		AddItem(a.list, 0, 1, true).
+		AddItem(a.tree, 0, 2, false).
+		AddItem(a.details, 0, 1, false)
+	a.layout = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(panes, 0, 1, true).
+		AddItem(a.status, 1, 0, false)
+
+	a.app.SetRoot(a.layout, true).SetInputCapture(a.handleKey)
+	return a
+}
+
+// Run lists the traces and runs the application until the user quits or the context is done.
+func (a *App) Run(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		a.app.Stop()
+	}()
+	go a.reload(ctx)
+	return a.app.Run()
+}
+
+func (a *App) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if a.app.GetFocus() == a.input {
+		return event
+	}
+	switch event.Key() {
+	case tcell.KeyTab:
+		switch a.app.GetFocus() {
+		case a.list:
+			a.app.SetFocus(a.tree)
+		case a.tree:
+			a.app.SetFocus(a.details)
+		default:
+			a.app.SetFocus(a.list)
+		}
+		return nil
+	case tcell.KeyRune:
+	default:
+		return event
+	}
+
+	switch event.Rune() {
+	case 'q':
+		a.app.Stop()
+	case '/':
+		a.input.SetText(a.filter)
+		a.layout.AddItem(a.input, 1, 0, true)
+		a.app.SetFocus(a.input)
+	case 'c':
+		a.nextCritical()
+	case 'y':
+		a.copyURL()
+	case 'e':
+		a.export()
+	case 'r':
+		go a.reload(context.Background())
+	default:
+		return event
+	}
+	return nil
+}
+
+func (a *App) setStatus(format string, args ...any) {
+	a.status.SetText(fmt.Sprintf(format, args...) + "  |  " + help)
+}
+
+func (a *App) reload(ctx context.Context) {
+	a.app.QueueUpdateDraw(func() { a.setStatus("listing traces of %s...", a.project) })
+	traces, err := a.tracer.List(ctx, a.project, a.limit, a.listOpts...)
+	a.app.QueueUpdateDraw(func() {
+		if err != nil {
+			a.setStatus("list traces: %v", err)
+			return
+		}
+		sort.SliceStable(traces, func(i, j int) bool {
+			si, _ := span.Interval(traces[i].GetSpans())
+			sj, _ := span.Interval(traces[j].GetSpans())
+			return si.After(sj)
+		})
+		a.traces = traces
+		a.render()
+		a.setStatus("%d traces", len(traces))
+	})
+}
+
+// render fills the list pane with the traces whose root name contains the filter.
+func (a *App) render() {
+	a.list.Clear()
+	for col, title := range []string{"START", "DURATION", "ROOT"} {
+		a.list.SetCell(0, col, tview.NewTableCell(title).SetSelectable(false).SetTextColor(tcell.ColorYellow))
+	}
+
+	a.visible = a.visible[:0]
+	for _, t := range a.traces {
+		root := span.Root(t.GetSpans())
+		if a.filter != "" && !strings.Contains(root.GetName(), a.filter) {
+			continue
+		}
+		a.visible = append(a.visible, t)
+		start, end := span.Interval(t.GetSpans())
+		row := len(a.visible)
+		a.list.SetCell(row, 0, tview.NewTableCell(start.Local().Format(time.TimeOnly)))
+		a.list.SetCell(row, 1, tview.NewTableCell(end.Sub(start).String()).SetAlign(tview.AlignRight))
+		a.list.SetCell(row, 2, tview.NewTableCell(tview.Escape(root.GetName())))
+	}
+	a.list.ScrollToBeginning()
+}
+
+// open fetches the whole trace and shows its spans.
+func (a *App) open(t *cloudtrace.Trace) {
+	a.setStatus("fetching %s...", t.GetTraceId())
+	go func() {
+		trace, err := a.tracer.Get(context.Background(), a.project, t.GetTraceId())
+		a.app.QueueUpdateDraw(func() {
+			if err != nil {
+				a.setStatus("get trace %s: %v", t.GetTraceId(), err)
+				return
+			}
+			a.showTrace(trace)
+			a.setStatus("%s: %d spans", trace.GetTraceId(), len(trace.GetSpans()))
+			a.app.SetFocus(a.tree)
+		})
+	}()
+}
+
+func (a *App) showTrace(trace *cloudtrace.Trace) {
+	a.current = trace
+	a.trace = model.FromV1(trace)
+	a.critical = span.CriticalPath(a.trace)
+	a.next = 0
+	a.nodes = make(map[uint64]*tview.TreeNode, len(trace.GetSpans()))
+
+	onPath := make(map[uint64]struct{}, len(a.critical))
+	for _, s := range a.critical {
+		onPath[s.ID] = struct{}{}
+	}
+	start, end := a.trace.Interval()
+
+	// the bars are aligned after the longest label, each tree level being indented by treeIndent columns.
+	width := 0
+	a.trace.DFS(func(s *model.Span) bool {
+		width = max(width, treeIndent*s.Depth+utf8.RuneCountInString(label(s)))
+		return true
+	})
+
+	var build func(s *model.Span) *tview.TreeNode
+	build = func(s *model.Span) *tview.TreeNode {
+		text := label(s)
+		padding := strings.Repeat(" ", width-treeIndent*s.Depth-utf8.RuneCountInString(text))
+		// the padding is measured on the displayed label, before escaping the tview tags it may contain.
+		node := tview.NewTreeNode(tview.Escape(text) + padding + " " + bar(s, start, end)).SetReference(s)
+		switch {
+		case span.IsError(s):
+			node.SetColor(tcell.ColorRed)
+		case hasKey(onPath, s.ID):
+			node.SetColor(tcell.ColorYellow)
+		}
+		a.nodes[s.ID] = node
+		for _, child := range s.Children {
+			node.AddChild(build(child))
+		}
+		return node
+	}
+
+	root := tview.NewTreeNode(trace.GetTraceId()).SetSelectable(false)
+	for _, top := range a.trace.Tops() {
+		root.AddChild(build(top))
+	}
+	a.tree.SetRoot(root).SetTopLevel(1)
+	if children := root.GetChildren(); len(children) > 0 {
+		a.tree.SetCurrentNode(children[0])
+		a.showSpan(children[0])
+	}
+}
+
+func label(s *model.Span) string {
+	return fmt.Sprintf("%s %s", s.Name, s.Duration())
+}
+
+// bar draws the span interval within the trace interval.
+func bar(s *model.Span, start, end time.Time) string {
+	total := end.Sub(start)
+	if total <= 0 || !s.Timed() {
+		return strings.Repeat("·", barWidth)
+	}
+	from := int(float64(s.Start.Sub(start)) / float64(total) * barWidth)
+	to := int(float64(s.End.Sub(start)) / float64(total) * barWidth)
+	from = min(max(from, 0), barWidth-1)
+	to = min(max(to, from+1), barWidth)
+	return strings.Repeat("·", from) + strings.Repeat("█", to-from) + strings.Repeat("·", barWidth-to)
+}
+
+func hasKey(m map[uint64]struct{}, key uint64) bool {
+	_, found := m[key]
+	return found
+}
+
+func (a *App) showSpan(node *tview.TreeNode) {
+	s, ok := node.GetReference().(*model.Span)
+	if !ok {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]name[-]: %s\n", tview.Escape(s.Name))
+	fmt.Fprintf(&b, "[yellow]span[-]: %d\n", s.ID)
+	if s.ParentID != 0 {
+		fmt.Fprintf(&b, "[yellow]parent[-]: %d\n", s.ParentID)
+	}
+	fmt.Fprintf(&b, "[yellow]kind[-]: %s\n", s.Kind)
+	fmt.Fprintf(&b, "[yellow]start[-]: %s\n", s.Start.Local().Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "[yellow]duration[-]: %s\n", s.Duration())
+	if span.IsError(s) {
+		b.WriteString("[red]error[-]\n")
+	}
+
+	keys := make([]string, 0, len(s.Attributes))
+	for key := range s.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		b.WriteString("\n[yellow]labels[-]\n")
+	}
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", tview.Escape(key), tview.Escape(s.Attribute(key)))
+	}
+	a.details.SetText(b.String()).ScrollToBeginning()
+}
+
+// nextCritical selects the next span of the critical path, expanding its ancestors.
+func (a *App) nextCritical() {
+	if len(a.critical) == 0 {
+		return
+	}
+	s := a.critical[a.next%len(a.critical)]
+	a.next++
+
+	for _, ancestor := range a.trace.Ancestors(s.ID) {
+		a.nodes[ancestor.ID].SetExpanded(true)
+	}
+	node := a.nodes[s.ID]
+	a.tree.SetCurrentNode(node)
+	a.showSpan(node)
+	a.app.SetFocus(a.tree)
+	a.setStatus("critical path %d/%d", (a.next-1)%len(a.critical)+1, len(a.critical))
+}
+
+// copyURL copies the console URL of the current trace to the clipboard with the OSC 52 terminal sequence.
+func (a *App) copyURL() {
+	if a.current == nil {
+		return
+	}
+	url := tracer.ConsoleURL(a.project, a.current.GetTraceId())
+	_, _ = fmt.Fprintf(os.Stdout, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(url)))
+	a.setStatus("copied %s", url)
+}
+
+// export writes the current trace to <trace id>.json in the working directory.
+func (a *App) export() {
+	if a.current == nil {
+		return
+	}
+	out, err := protojson.Marshal(a.current)
+	if err != nil {
+		a.setStatus("marshal trace: %v", err)
+		return
+	}
+	path := a.current.GetTraceId() + ".json"
+	if err = os.WriteFile(path, out, 0o644); err != nil {
+		a.setStatus("export trace: %v", err)
+		return
+	}
+	a.setStatus("exported %s", path)
+}
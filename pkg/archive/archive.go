@@ -0,0 +1,252 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/filter"
+	"github.com/moshebe/gtrace/pkg/span"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	indexFile = "index.ndjson"
+	tracesDir = "traces"
+)
+
+// Archive is a durable local store of traces. Every trace is kept as a JSON file and summarized by a record in an
+// append-only index, which is what searches run against.
+type Archive struct {
+	dir string
+}
+
+// Record summarizes an archived trace for searching without loading it.
+type Record struct {
+	Project string              `json:"project"`
+	TraceID string              `json:"traceId"`
+	Root    string              `json:"root"`
+	Start   time.Time           `json:"start"`
+	End     time.Time           `json:"end"`
+	Spans   int                 `json:"spans"`
+	Names   []string            `json:"names,omitempty"`
+	Labels  map[string][]string `json:"labels,omitempty"`
+	Added   time.Time           `json:"added"`
+}
+
+func (r *Record) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Query holds the conditions a record must satisfy. Zero valued conditions are ignored.
+type Query struct {
+	Project     string
+	Root        *filter.Filter
+	Labels      map[string]*filter.Filter
+	From, To    time.Time
+	MinDuration time.Duration
+	MaxDuration time.Duration
+}
+
+// Match reports whether the record satisfies all the query conditions. A label condition is satisfied when any
+// span of the trace has the label with a value passing its filter.
+func (q *Query) Match(r *Record) bool {
+	if q.Project != "" && q.Project != r.Project {
+		return false
+	}
+	if q.Root != nil && !q.Root.Pass(r.Root) {
+		return false
+	}
+	if !q.From.IsZero() && r.Start.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && r.Start.After(q.To) {
+		return false
+	}
+	if q.MinDuration > 0 && r.Duration() < q.MinDuration {
+		return false
+	}
+	if q.MaxDuration > 0 && r.Duration() > q.MaxDuration {
+		return false
+	}
+	for key, f := range q.Labels {
+		matched := false
+		for _, value := range r.Labels[key] {
+			if f.Pass(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultDir returns the archive directory under the user data directory, following the XDG base directory spec.
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "gtrace", "archive"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("user home dir: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "gtrace", "archive"), nil
+}
+
+func Open(dir string) (*Archive, error) {
+	if err := os.MkdirAll(filepath.Join(dir, tracesDir), 0o755); err != nil {
+		return nil, fmt.Errorf("create archive dir: %w", err)
+	}
+	return &Archive{dir: dir}, nil
+}
+
+// Dir returns the directory the archive is stored in.
+func (a *Archive) Dir() string {
+	return a.dir
+}
+
+func (a *Archive) path(projectID, traceID string) string {
+	return filepath.Join(a.dir, tracesDir, filepath.Base(projectID), filepath.Base(traceID)+".json")
+}
+
+// NewRecord summarizes the given trace.
+func NewRecord(trace *cloudtrace.Trace) *Record {
+	start, end := span.Interval(trace.GetSpans())
+	labels := make(map[string][]string)
+	seen := make(map[string]map[string]struct{})
+	for _, s := range trace.GetSpans() {
+		for key, value := range s.GetLabels() {
+			if seen[key] == nil {
+				seen[key] = make(map[string]struct{})
+			}
+			if _, found := seen[key][value]; found {
+				continue
+			}
+			seen[key][value] = struct{}{}
+			labels[key] = append(labels[key], value)
+		}
+	}
+	names := span.Names(trace.GetSpans())
+	sort.Strings(names)
+
+	return &Record{
+		Project: trace.GetProjectId(),
+		TraceID: trace.GetTraceId(),
+		Root:    span.Root(trace.GetSpans()).GetName(),
+		Start:   start,
+		End:     end,
+		Spans:   len(trace.GetSpans()),
+		Names:   names,
+		Labels:  labels,
+	}
+}
+
+// Add stores the traces and indexes them. Adding an already archived trace replaces it.
+func (a *Archive) Add(traces ...*cloudtrace.Trace) error {
+	index, err := os.OpenFile(filepath.Join(a.dir, indexFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open index: %w", err)
+	}
+	defer func() { _ = index.Close() }()
+
+	now := time.Now()
+	for _, trace := range traces {
+		if trace.GetTraceId() == "" {
+			return errors.New("missing trace id")
+		}
+		out, err := protojson.Marshal(trace)
+		if err != nil {
+			return fmt.Errorf("marshal trace %q: %w", trace.GetTraceId(), err)
+		}
+		path := a.path(trace.GetProjectId(), trace.GetTraceId())
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("create project dir: %w", err)
+		}
+		if err = os.WriteFile(path, out, 0o644); err != nil {
+			return fmt.Errorf("write trace %q: %w", trace.GetTraceId(), err)
+		}
+
+		record := NewRecord(trace)
+		record.Added = now
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("marshal record: %w", err)
+		}
+		if _, err = fmt.Fprintln(index, string(line)); err != nil {
+			return fmt.Errorf("write index: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get loads an archived trace.
+func (a *Archive) Get(projectID, traceID string) (*cloudtrace.Trace, error) {
+	in, err := os.ReadFile(a.path(projectID, traceID))
+	if err != nil {
+		return nil, fmt.Errorf("read trace: %w", err)
+	}
+	var trace cloudtrace.Trace
+	if err = protojson.Unmarshal(in, &trace); err != nil {
+		return nil, fmt.Errorf("unmarshal trace: %w", err)
+	}
+	return &trace, nil
+}
+
+// Records returns the index records of all archived traces, ordered by trace start time.
+func (a *Archive) Records() ([]*Record, error) {
+	f, err := os.Open(filepath.Join(a.dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open index: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	latest := make(map[string]*Record)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		latest[r.Project+"/"+r.TraceID] = &r
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+
+	records := make([]*Record, 0, len(latest))
+	for _, r := range latest {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Start.Before(records[j].Start)
+	})
+	return records, nil
+}
+
+// Search returns the records matching the query, ordered by trace start time.
+func (a *Archive) Search(q *Query) ([]*Record, error) {
+	records, err := a.Records()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Record, 0, len(records))
+	for _, r := range records {
+		if q.Match(r) {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
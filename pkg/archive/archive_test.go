@@ -0,0 +1,100 @@
+package archive
+
+import (
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/filter"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newTrace(id, root string, start time.Time, duration time.Duration, labels map[string]string) *cloudtrace.Trace {
+	return &cloudtrace.Trace{
+		ProjectId: "project",
+		TraceId:   id,
+		Spans: []*cloudtrace.TraceSpan{
+			{
+				SpanId:    1,
+				Name:      root,
+				StartTime: timestamppb.New(start),
+				EndTime:   timestamppb.New(start.Add(duration)),
+			},
+			{
+				SpanId:       2,
+				ParentSpanId: 1,
+				Name:         "child",
+				StartTime:    timestamppb.New(start),
+				EndTime:      timestamppb.New(start.Add(duration / 2)),
+				Labels:       labels,
+			},
+		},
+	}
+}
+
+func mustFilter(t *testing.T, pattern string) *filter.Filter {
+	t.Helper()
+	f, err := filter.New([]string{pattern}, true)
+	if err != nil {
+		t.Fatalf("failed to create filter: %v", err)
+	}
+	return f
+}
+
+func TestSearch(t *testing.T) {
+	a, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	err = a.Add(
+		newTrace("a", "/api/search", now, time.Second, map[string]string{"/http/status_code": "200"}),
+		newTrace("b", "/api/search", now.Add(time.Hour), 3*time.Second, map[string]string{"/http/status_code": "500"}),
+		newTrace("c", "/api/pay", now.Add(2*time.Hour), 2*time.Second, nil),
+	)
+	if err != nil {
+		t.Fatalf("failed to add traces: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query *Query
+		want  []string
+	}{
+		{name: "everything", query: &Query{}, want: []string{"a", "b", "c"}},
+		{name: "root name", query: &Query{Root: mustFilter(t, "^/api/search$")}, want: []string{"a", "b"}},
+		{name: "label value", query: &Query{Labels: map[string]*filter.Filter{"/http/status_code": mustFilter(t, "^5")}}, want: []string{"b"}},
+		{name: "time range", query: &Query{From: now.Add(30 * time.Minute), To: now.Add(90 * time.Minute)}, want: []string{"b"}},
+		{name: "duration", query: &Query{MinDuration: 2 * time.Second}, want: []string{"b", "c"}},
+		{name: "other project", query: &Query{Project: "other"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := a.Search(tt.query)
+			if err != nil {
+				t.Fatalf("failed to search: %v", err)
+			}
+			var got []string
+			for _, r := range records {
+				got = append(got, r.TraceID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search()=%v want: %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Search()=%v want: %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	trace, err := a.Get("project", "b")
+	if err != nil {
+		t.Fatalf("failed to get trace: %v", err)
+	}
+	if len(trace.GetSpans()) != 2 {
+		t.Fatalf("unexpected number of spans: %d", len(trace.GetSpans()))
+	}
+}
@@ -0,0 +1,354 @@
+// Package assert evaluates rules about span latencies, errors and counts against traces.
+//
+// A rules file has one rule per line. Empty lines and lines starting with '#' are ignored:
+//
+//	[<name>:] [root <pattern>] span <pattern> <metric> <op> <value> [over <duration>]
+//
+// Patterns are regular expressions matched against span names, anchor them with ^ and $ for exact matches, '*'
+// matches any span. Patterns with spaces can be double-quoted. Metrics are p50, p95, p99, min, max and mean,
+// compared to durations over all the matching spans, errors, the number of failed matching spans according to the
+// default error rules, and count, the number of matching spans compared per trace. Operators are <, <=, >, >=, ==
+// and !=. With root, only the traces whose root span name matches are evaluated, and with over, only the traces
+// started within the given duration before the reference time. For example:
+//
+//	pay-latency: span ^/api/pay$ p99 < 300ms over 30m
+//	root ^/api/checkout$ span * errors == 0
+//	span ^db count <= 20
+package assert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/filter"
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
+)
+
+// Metric is the measure of the matching spans a rule compares.
+type Metric string
+
+const (
+	MetricP50    Metric = "p50"
+	MetricP95    Metric = "p95"
+	MetricP99    Metric = "p99"
+	MetricMin    Metric = "min"
+	MetricMax    Metric = "max"
+	MetricMean   Metric = "mean"
+	MetricErrors Metric = "errors"
+	MetricCount  Metric = "count"
+)
+
+func (m Metric) duration() bool {
+	switch m {
+	case MetricP50, MetricP95, MetricP99, MetricMin, MetricMax, MetricMean:
+		return true
+	}
+	return false
+}
+
+var operators = map[string]func(a, b float64) bool{
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// Rule is a parsed assertion.
+type Rule struct {
+	Name string
+	// Line is the line of the rule in its file, zero for rules parsed on their own.
+	Line int
+	// Root and Span filter the root and the evaluated span names. They are nil for '*'.
+	Root   *filter.Filter
+	Span   *filter.Filter
+	Metric Metric
+	Op     string
+	// Value is the threshold, in nanoseconds for duration metrics.
+	Value float64
+	// Window restricts the traces to the ones started within it before the reference time. Zero means all of them.
+	Window time.Duration
+	text   string
+}
+
+// String returns the rule as written.
+func (r Rule) String() string {
+	return r.text
+}
+
+// Result is the outcome of a rule.
+type Result struct {
+	Rule     string `json:"rule"`
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Traces   int    `json:"traces"`
+	Spans    int    `json:"spans"`
+	Observed string `json:"observed"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Parse reads the rules, one per line.
+func Parse(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := ParseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rule.Line = line
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+	return rules, nil
+}
+
+// ParseRule parses a single rule.
+func ParseRule(text string) (Rule, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule := Rule{text: text}
+
+	next := func(what string) (string, error) {
+		if len(tokens) == 0 {
+			return "", fmt.Errorf("missing %s in %q", what, text)
+		}
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token, nil
+	}
+
+	if len(tokens) > 0 && strings.HasSuffix(tokens[0], ":") {
+		rule.Name = strings.TrimSuffix(tokens[0], ":")
+		tokens = tokens[1:]
+	}
+	if len(tokens) > 0 && tokens[0] == "root" {
+		tokens = tokens[1:]
+		pattern, err := next("root pattern")
+		if err != nil {
+			return Rule{}, err
+		}
+		if rule.Root, err = newFilter(pattern); err != nil {
+			return Rule{}, err
+		}
+	}
+
+	keyword, err := next("span keyword")
+	if err != nil {
+		return Rule{}, err
+	}
+	if keyword != "span" {
+		return Rule{}, fmt.Errorf("expected span, got %q in %q", keyword, text)
+	}
+	pattern, err := next("span pattern")
+	if err != nil {
+		return Rule{}, err
+	}
+	if rule.Span, err = newFilter(pattern); err != nil {
+		return Rule{}, err
+	}
+
+	metric, err := next("metric")
+	if err != nil {
+		return Rule{}, err
+	}
+	rule.Metric = Metric(metric)
+	if !rule.Metric.duration() && rule.Metric != MetricErrors && rule.Metric != MetricCount {
+		return Rule{}, fmt.Errorf("unsupported metric %q in %q", metric, text)
+	}
+
+	if rule.Op, err = next("operator"); err != nil {
+		return Rule{}, err
+	}
+	if _, found := operators[rule.Op]; !found {
+		return Rule{}, fmt.Errorf("unsupported operator %q in %q", rule.Op, text)
+	}
+
+	value, err := next("value")
+	if err != nil {
+		return Rule{}, err
+	}
+	if rule.Metric.duration() {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid duration %q in %q", value, text)
+		}
+		rule.Value = float64(d)
+	} else {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid number %q in %q", value, text)
+		}
+		rule.Value = float64(n)
+	}
+
+	if len(tokens) > 0 && tokens[0] == "over" {
+		tokens = tokens[1:]
+		window, err := next("window")
+		if err != nil {
+			return Rule{}, err
+		}
+		if rule.Window, err = time.ParseDuration(window); err != nil || rule.Window <= 0 {
+			return Rule{}, fmt.Errorf("invalid window %q in %q", window, text)
+		}
+	}
+	if len(tokens) > 0 {
+		return Rule{}, fmt.Errorf("unexpected %q in %q", strings.Join(tokens, " "), text)
+	}
+
+	if rule.Name == "" {
+		rule.Name = text
+	}
+	return rule, nil
+}
+
+func newFilter(pattern string) (*filter.Filter, error) {
+	if pattern == "*" {
+		return nil, nil
+	}
+	return filter.New([]string{pattern}, true)
+}
+
+func tokenize(text string) ([]string, error) {
+	var tokens []string
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		if text[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string in %q", text)
+			}
+			token, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, token)
+			text = text[len(quoted):]
+			continue
+		}
+		end := strings.IndexAny(text, " \t")
+		if end < 0 {
+			end = len(text)
+		}
+		tokens = append(tokens, text[:end])
+		text = text[end:]
+	}
+	return tokens, nil
+}
+
+// Evaluate checks the rule against the traces, with windows ending at now. A rule fails when no trace matches,
+// and a rule on durations also fails when no timed span matches.
+func (r Rule) Evaluate(traces []*model.Trace, now time.Time) Result {
+	result := Result{Rule: r.text, Name: r.Name}
+
+	var spans []*model.Span
+	var counts []int
+	var traceIDs []string
+	for _, t := range traces {
+		root := t.Root()
+		if root == nil || (r.Root != nil && !r.Root.Pass(root.Name)) {
+			continue
+		}
+		if r.Window > 0 && root.Start.Before(now.Add(-r.Window)) {
+			continue
+		}
+		count := 0
+		for _, s := range t.Spans {
+			// spans missing a timestamp have no duration to measure.
+			if r.Metric.duration() && !s.Timed() {
+				continue
+			}
+			if r.Span == nil || r.Span.Pass(s.Name) {
+				spans = append(spans, s)
+				count++
+			}
+		}
+		counts = append(counts, count)
+		traceIDs = append(traceIDs, t.TraceID)
+	}
+	result.Traces, result.Spans = len(counts), len(spans)
+	compare := operators[r.Op]
+
+	switch {
+	case len(counts) == 0:
+		result.Observed = "no traces"
+		result.Message = "no matching traces"
+	case r.Metric == MetricCount:
+		violations, first := 0, ""
+		for i, count := range counts {
+			if !compare(float64(count), r.Value) {
+				if violations == 0 {
+					first = fmt.Sprintf("trace %s has %d", traceIDs[i], count)
+				}
+				violations++
+			}
+		}
+		result.Passed = violations == 0
+		result.Observed = fmt.Sprintf("%d/%d traces violating", violations, len(counts))
+		if !result.Passed {
+			result.Message = fmt.Sprintf("%d traces have a span count not %s %d, %s", violations, r.Op, int(r.Value), first)
+		}
+	case r.Metric == MetricErrors:
+		errors := span.DefaultErrorRules.CountErrors(spans)
+		result.Passed = compare(float64(errors), r.Value)
+		result.Observed = strconv.Itoa(errors)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("%d failed spans, expected %s %d", errors, r.Op, int(r.Value))
+		}
+	case len(spans) == 0:
+		result.Observed = "no spans"
+		result.Message = "no matching spans"
+	default:
+		observed := r.observe(spans)
+		result.Passed = compare(float64(observed), r.Value)
+		result.Observed = observed.String()
+		if !result.Passed {
+			result.Message = fmt.Sprintf("%s is %s, expected %s %s", r.Metric, observed, r.Op, time.Duration(r.Value))
+		}
+	}
+	return result
+}
+
+func (r Rule) observe(spans []*model.Span) time.Duration {
+	durations := make([]time.Duration, 0, len(spans))
+	for _, s := range spans {
+		durations = append(durations, s.Duration())
+	}
+	stats := span.NewDurationStats(durations)
+	switch r.Metric {
+	case MetricP50:
+		return stats.P50
+	case MetricP95:
+		return stats.P95
+	case MetricP99:
+		return stats.P99
+	case MetricMin:
+		return stats.Min
+	case MetricMax:
+		return stats.Max
+	default:
+		return stats.Mean
+	}
+}
+
+// MaxWindow returns the largest window of the rules, or zero if any rule evaluates all the traces.
+func MaxWindow(rules []Rule) time.Duration {
+	var window time.Duration
+	for _, r := range rules {
+		if r.Window == 0 {
+			return 0
+		}
+		window = max(window, r.Window)
+	}
+	return window
+}
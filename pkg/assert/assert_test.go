@@ -0,0 +1,109 @@
+package assert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestParse(t *testing.T) {
+	rules, err := Parse(strings.NewReader(`
+# latency
+pay-latency: span ^/api/pay$ p99 < 300ms over 30m
+root "^/api/checkout$" span * errors == 0
+span ^db count <= 20
+`))
+	if err != nil {
+		t.Fatalf("failed to parse rules: %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("unexpected number of rules: %d", len(rules))
+	}
+	if r := rules[0]; r.Name != "pay-latency" || r.Line != 3 || r.Metric != MetricP99 || r.Value != float64(300*time.Millisecond) || r.Window != 30*time.Minute {
+		t.Fatalf("unexpected rule: %+v", r)
+	}
+	if r := rules[1]; r.Root == nil || r.Span != nil || r.Metric != MetricErrors || r.Op != "==" {
+		t.Fatalf("unexpected rule: %+v", r)
+	}
+
+	invalid := []string{
+		"span",
+		"span db p42 < 1s",
+		"span db p99 ~ 1s",
+		"span db p99 < 12",
+		"span db count < 1s",
+		"span db p99 < 1s over",
+		"span db p99 < 1s extra",
+		"spans db p99 < 1s",
+	}
+	for _, text := range invalid {
+		if _, err := ParseRule(text); err == nil {
+			t.Fatalf("ParseRule(%q) expected an error", text)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newTrace := func(id, root string, ago, duration time.Duration, queries int, status string) *model.Trace {
+		start := now.Add(-ago)
+		newSpan := func(id, parent uint64, name string) *cloudtrace.TraceSpan {
+			return &cloudtrace.TraceSpan{
+				SpanId:       id,
+				ParentSpanId: parent,
+				Name:         name,
+				StartTime:    timestamppb.New(start),
+				EndTime:      timestamppb.New(start.Add(duration)),
+			}
+		}
+		spans := []*cloudtrace.TraceSpan{newSpan(1, 0, root)}
+		spans[0].Labels = map[string]string{"/http/status_code": status}
+		for i := 0; i < queries; i++ {
+			spans = append(spans, newSpan(uint64(10+i), 1, "db"))
+		}
+		return model.FromV1(&cloudtrace.Trace{TraceId: id, Spans: spans})
+	}
+	// an untimed pay span, whose duration would be negative, is ignored by latency rules.
+	untimed := newTrace("5", "/api/pay", time.Minute, 100*time.Millisecond, 0, "200")
+	untimed.Span(1).End = time.Time{}
+	traces := []*model.Trace{
+		newTrace("1", "/api/pay", time.Minute, 100*time.Millisecond, 2, "200"),
+		newTrace("2", "/api/pay", 10*time.Minute, 200*time.Millisecond, 30, "200"),
+		newTrace("3", "/api/pay", time.Hour, time.Second, 1, "200"),
+		newTrace("4", "/api/checkout", time.Minute, 50*time.Millisecond, 1, "503"),
+		untimed,
+	}
+
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{rule: "span ^/api/pay$ p99 < 300ms over 30m", want: true},
+		{rule: "span ^/api/pay$ p99 < 300ms", want: false},
+		{rule: "span ^/api/pay$ max >= 1s", want: true},
+		{rule: "span ^/api/pay$ min >= 100ms", want: true},
+		{rule: "root ^/api/pay$ span * errors == 0", want: true},
+		{rule: "root ^/api/checkout$ span * errors == 0", want: false},
+		{rule: "span ^db$ count <= 20", want: false},
+		{rule: "root ^/api/checkout$ span ^db$ count <= 20", want: true},
+		{rule: "span ^/unknown$ p50 < 1s", want: false},
+		{rule: "root ^/unknown$ span * errors == 0", want: false},
+		{rule: "root ^/unknown$ span * count <= 20", want: false},
+		{rule: "span * errors == 0 over 1s", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			if err != nil {
+				t.Fatalf("failed to parse rule: %v", err)
+			}
+			if got := rule.Evaluate(traces, now); got.Passed != tt.want {
+				t.Fatalf("Evaluate()=%+v want passed: %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	DefaultTTL     = 7 * 24 * time.Hour
+	DefaultMaxSize = 512 << 20
+
+	fileExt = ".json"
+)
+
+// Cache is an on-disk store of API responses. Every entry lives in its own file named by the hash of its key.
+// The file modification time tracks the last access and drives the LRU eviction once the size cap is exceeded.
+type Cache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	now     func() time.Time
+}
+
+type Option func(c *Cache)
+
+// WithTTL sets how long an entry is served after it was stored. Zero means entries never expire.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithMaxSize sets the total size in bytes the cache may occupy. Zero means no limit.
+func WithMaxSize(size int64) Option {
+	return func(c *Cache) {
+		c.maxSize = size
+	}
+}
+
+// Entry is a single cached value along with its metadata.
+type Entry struct {
+	Key      string          `json:"key"`
+	Created  time.Time       `json:"created"`
+	Data     json.RawMessage `json:"data"`
+	Path     string          `json:"-"`
+	Size     int64           `json:"-"`
+	Accessed time.Time       `json:"-"`
+}
+
+// DefaultDir returns the cache directory under the user cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "gtrace"), nil
+}
+
+func New(dir string, opts ...Option) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	c := &Cache{
+		dir:     dir,
+		ttl:     DefaultTTL,
+		maxSize: DefaultMaxSize,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// TraceKey returns the key of a single trace.
+func TraceKey(projectID, traceID string) string {
+	return "trace/" + projectID + "/" + traceID
+}
+
+// Dir returns the directory the cache is stored in.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+fileExt)
+}
+
+func (c *Cache) expired(e *Entry) bool {
+	return c.ttl > 0 && c.now().Sub(e.Created) > c.ttl
+}
+
+// Get loads the value stored under key into m. It reports whether a fresh entry was found.
+func (c *Cache) Get(key string, m proto.Message) (bool, error) {
+	path := c.path(key)
+	e, err := readEntry(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if e.Key != key {
+		return false, nil
+	}
+	if c.expired(e) {
+		_ = os.Remove(path)
+		return false, nil
+	}
+	if err = protojson.Unmarshal(e.Data, m); err != nil {
+		return false, fmt.Errorf("unmarshal entry: %w", err)
+	}
+	now := c.now()
+	_ = os.Chtimes(path, now, now)
+	return true, nil
+}
+
+// Put stores m under key, evicting the least recently used entries if the cache grows beyond its size cap.
+func (c *Cache) Put(key string, m proto.Message) error {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal value: %w", err)
+	}
+	out, err := json.Marshal(&Entry{Key: key, Created: c.now(), Data: data})
+	if err != nil {
+		return fmt.Errorf("marshal entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("create entry: %w", err)
+	}
+	_, err = tmp.Write(out)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write entry: %w", err)
+	}
+
+	_, err = c.evict()
+	return err
+}
+
+// Entries returns all cached entries, most recently accessed first. The entries data is not loaded.
+func (c *Cache) Entries() ([]*Entry, error) {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read cache dir: %w", err)
+	}
+
+	entries := make([]*Entry, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileExt) {
+			continue
+		}
+		e, err := readEntry(filepath.Join(c.dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		e.Data = nil
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Accessed.After(entries[j].Accessed)
+	})
+	return entries, nil
+}
+
+// Prune removes the expired entries and evicts the least recently used ones beyond the size cap.
+// It returns the number of removed entries.
+func (c *Cache) Prune() (int, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		if !c.expired(e) {
+			continue
+		}
+		if err = os.Remove(e.Path); err != nil {
+			return removed, fmt.Errorf("remove entry: %w", err)
+		}
+		removed++
+	}
+
+	evicted, err := c.evict()
+	return removed + evicted, err
+}
+
+// Clear removes all the cached entries and returns their number.
+func (c *Cache) Clear() (int, error) {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return 0, fmt.Errorf("read cache dir: %w", err)
+	}
+	removed := 0
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileExt) {
+			continue
+		}
+		if err = os.Remove(filepath.Join(c.dir, f.Name())); err != nil {
+			return removed, fmt.Errorf("remove entry: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// evict removes the least recently used entries until the cache fits its size cap.
+func (c *Cache) evict() (int, error) {
+	if c.maxSize <= 0 {
+		return 0, nil
+	}
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return 0, fmt.Errorf("read cache dir: %w", err)
+	}
+
+	var total int64
+	infos := make([]os.FileInfo, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileExt) {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		infos = append(infos, info)
+	}
+	if total <= c.maxSize {
+		return 0, nil
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	removed := 0
+	for _, info := range infos {
+		if total <= c.maxSize {
+			break
+		}
+		if err = os.Remove(filepath.Join(c.dir, info.Name())); err != nil {
+			return removed, fmt.Errorf("remove entry: %w", err)
+		}
+		total -= info.Size()
+		removed++
+	}
+	return removed, nil
+}
+
+func readEntry(path string) (*Entry, error) {
+	in, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var e Entry
+	if err = json.Unmarshal(in, &e); err != nil {
+		return nil, fmt.Errorf("unmarshal entry: %w", err)
+	}
+	e.Path = path
+	e.Size = info.Size()
+	e.Accessed = info.ModTime()
+	return &e, nil
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+)
+
+func TestGetPut(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c, err := New(t.TempDir(), WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	c.now = func() time.Time { return now }
+
+	key := TraceKey("project", "abc")
+	if err = c.Put(key, &cloudtrace.Trace{ProjectId: "project", TraceId: "abc"}); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		after time.Duration
+		want  bool
+	}{
+		{name: "hit", key: key, want: true},
+		{name: "unknown key", key: TraceKey("project", "def"), want: false},
+		{name: "expired", key: key, after: 2 * time.Hour, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.now = func() time.Time { return now.Add(tt.after) }
+			var trace cloudtrace.Trace
+			found, err := c.Get(tt.key, &trace)
+			if err != nil {
+				t.Fatalf("failed to get: %v", err)
+			}
+			if found != tt.want {
+				t.Fatalf("Get(%q)=%v want: %v", tt.key, found, tt.want)
+			}
+			if found && trace.GetTraceId() != "abc" {
+				t.Fatalf("unexpected trace id: %q", trace.GetTraceId())
+			}
+		})
+	}
+}
+
+func TestEvict(t *testing.T) {
+	c, err := New(t.TempDir(), WithTTL(0), WithMaxSize(0))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	base := time.Now().Add(-time.Hour)
+	ids := []string{"a", "b", "c"}
+	for i, id := range ids {
+		trace := &cloudtrace.Trace{TraceId: strings.Repeat(id, 100)}
+		if err = c.Put(TraceKey("p", id), trace); err != nil {
+			t.Fatalf("failed to put: %v", err)
+		}
+		accessed := base.Add(time.Duration(i) * time.Minute)
+		if err = os.Chtimes(c.path(TraceKey("p", id)), accessed, accessed); err != nil {
+			t.Fatalf("failed to set access time: %v", err)
+		}
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatalf("failed to list entries: %v", err)
+	}
+	c.maxSize = entries[0].Size + entries[1].Size
+
+	removed, err := c.Prune()
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Prune()=%d want: 1", removed)
+	}
+	if found, _ := c.Get(TraceKey("p", "a"), &cloudtrace.Trace{}); found {
+		t.Fatalf("least recently used entry was not evicted")
+	}
+	if found, _ := c.Get(TraceKey("p", "c"), &cloudtrace.Trace{}); !found {
+		t.Fatalf("most recently used entry was evicted")
+	}
+}
@@ -0,0 +1,293 @@
+// Package config reads and writes the configuration file, which holds named profiles of default flag values.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/span"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is the profile used when none is selected.
+const DefaultProfile = "default"
+
+// Config is the configuration file:
+//
+//	profiles:
+//	  default:
+//	    projects: [production-a, production-b]
+//	    format: text
+//	    time-zone: Europe/London
+//	    service-rules: [service.name, k8s.pod.name=^(.+)-[a-z0-9]+-[a-z0-9]+$]
+//	    queries:
+//	      slow-checkout:
+//	        filter: [root:/checkout, latency:500ms]
+//	        since: 6h
+//	        limit: 200
+type Config struct {
+	Profiles map[string]*Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile holds the values of flags not set on the command line.
+type Profile struct {
+	Projects     []string          `yaml:"projects,omitempty"`
+	Format       string            `yaml:"format,omitempty"`
+	TimeZone     string            `yaml:"time-zone,omitempty"`
+	ServiceRules []string          `yaml:"service-rules,omitempty"`
+	Queries      map[string]*Query `yaml:"queries,omitempty"`
+}
+
+// Query is a saved set of trace listing flags, selected with --query.
+type Query struct {
+	Filter []string      `yaml:"filter,omitempty"`
+	Since  time.Duration `yaml:"since,omitempty"`
+	Limit  int           `yaml:"limit,omitempty"`
+}
+
+// DefaultPath returns the configuration file under the user config directory, following the XDG base directory spec.
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gtrace", "config.yaml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("user home dir: %w", err)
+	}
+	return filepath.Join(home, ".config", "gtrace", "config.yaml"), nil
+}
+
+// Load reads the configuration file. A missing file is an empty configuration.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	in, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err = yaml.Unmarshal(in, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	for name, profile := range cfg.Profiles {
+		if profile == nil {
+			cfg.Profiles[name] = &Profile{}
+		}
+	}
+	return cfg, nil
+}
+
+// Save writes the configuration file, creating its directory if needed.
+func (c *Config) Save(path string) error {
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+// Profile returns the named profile, or nil if it does not exist.
+func (c *Config) Profile(name string) *Profile {
+	return c.Profiles[name]
+}
+
+// Names returns the sorted profile names.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the values of a key: projects, format, time-zone, service-rules or queries.<name>.<filter|since|limit>.
+func (p *Profile) Get(key string) ([]string, error) {
+	switch key {
+	case "projects":
+		return p.Projects, nil
+	case "format":
+		return nonEmpty(p.Format), nil
+	case "time-zone":
+		return nonEmpty(p.TimeZone), nil
+	case "service-rules":
+		return p.ServiceRules, nil
+	}
+
+	name, field, err := queryKey(key)
+	if err != nil {
+		return nil, err
+	}
+	q := p.Queries[name]
+	if q == nil {
+		return nil, nil
+	}
+	switch field {
+	case "filter":
+		return q.Filter, nil
+	case "since":
+		if q.Since == 0 {
+			return nil, nil
+		}
+		return []string{q.Since.String()}, nil
+	default:
+		if q.Limit == 0 {
+			return nil, nil
+		}
+		return []string{strconv.Itoa(q.Limit)}, nil
+	}
+}
+
+// Set validates and sets the values of a key. No values unset the key.
+func (p *Profile) Set(key string, values []string) error {
+	single := func() (string, error) {
+		if len(values) > 1 {
+			return "", fmt.Errorf("%s takes a single value", key)
+		}
+		if len(values) == 0 {
+			return "", nil
+		}
+		return values[0], nil
+	}
+
+	switch key {
+	case "projects":
+		p.Projects = nil
+		for _, v := range values {
+			p.Projects = append(p.Projects, strings.Split(v, ",")...)
+		}
+		return nil
+	case "format":
+		format, err := single()
+		if err != nil {
+			return err
+		}
+		if format != "" && format != "json" && format != "text" {
+			return fmt.Errorf("unsupported format: %s (supported formats: json, text)", format)
+		}
+		p.Format = format
+		return nil
+	case "time-zone":
+		tz, err := single()
+		if err != nil {
+			return err
+		}
+		if _, err = time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("time zone: %w", err)
+		}
+		p.TimeZone = tz
+		return nil
+	case "service-rules":
+		for _, v := range values {
+			if _, err := span.ParseServiceRule(v); err != nil {
+				return err
+			}
+		}
+		p.ServiceRules = values
+		return nil
+	}
+
+	name, field, err := queryKey(key)
+	if err != nil {
+		return err
+	}
+	q := p.Queries[name]
+	if q == nil {
+		q = &Query{}
+	}
+	switch field {
+	case "filter":
+		q.Filter = values
+	case "since":
+		value, err := single()
+		if err != nil {
+			return err
+		}
+		q.Since = 0
+		if value != "" {
+			if q.Since, err = time.ParseDuration(value); err != nil {
+				return fmt.Errorf("invalid duration %q", value)
+			}
+		}
+	default:
+		value, err := single()
+		if err != nil {
+			return err
+		}
+		q.Limit = 0
+		if value != "" {
+			if q.Limit, err = strconv.Atoi(value); err != nil || q.Limit < 0 {
+				return fmt.Errorf("invalid limit %q", value)
+			}
+		}
+	}
+
+	if p.Queries == nil {
+		p.Queries = make(map[string]*Query)
+	}
+	p.Queries[name] = q
+	if len(q.Filter) == 0 && q.Since == 0 && q.Limit == 0 {
+		delete(p.Queries, name)
+	}
+	return nil
+}
+
+// Keys returns the keys set in the profile, in the order of the configuration file.
+func (p *Profile) Keys() []string {
+	var keys []string
+	for _, key := range []string{"projects", "format", "time-zone", "service-rules"} {
+		if values, _ := p.Get(key); len(values) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	names := make([]string, 0, len(p.Queries))
+	for name := range p.Queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, field := range []string{"filter", "since", "limit"} {
+			key := "queries." + name + "." + field
+			if values, _ := p.Get(key); len(values) > 0 {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+func queryKey(key string) (string, string, error) {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 || parts[0] != "queries" || parts[1] == "" {
+		return "", "", fmt.Errorf("unsupported key: %s (supported keys: projects, format, time-zone, service-rules, "+
+			"queries.<name>.filter, queries.<name>.since, queries.<name>.limit)", key)
+	}
+	switch parts[2] {
+	case "filter", "since", "limit":
+		return parts[1], parts[2], nil
+	}
+	return "", "", fmt.Errorf("unsupported query key: %s (supported keys: filter, since, limit)", parts[2])
+}
+
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
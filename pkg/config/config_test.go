@@ -0,0 +1,92 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		values  []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "projects split by comma", key: "projects", values: []string{"a,b", "c"}, want: []string{"a", "b", "c"}},
+		{name: "format", key: "format", values: []string{"json"}, want: []string{"json"}},
+		{name: "unsupported format", key: "format", values: []string{"yaml"}, wantErr: true},
+		{name: "multiple values", key: "format", values: []string{"json", "text"}, wantErr: true},
+		{name: "time zone", key: "time-zone", values: []string{"Europe/London"}, want: []string{"Europe/London"}},
+		{name: "invalid time zone", key: "time-zone", values: []string{"Mars/Olympus"}, wantErr: true},
+		{name: "service rules", key: "service-rules", values: []string{"service.name", "pod=^(.+)-[a-z0-9]+$"}, want: []string{"service.name", "pod=^(.+)-[a-z0-9]+$"}},
+		{name: "invalid service rule", key: "service-rules", values: []string{"pod=("}, wantErr: true},
+		{name: "query filter", key: "queries.slow.filter", values: []string{"root:/api", "latency:1s"}, want: []string{"root:/api", "latency:1s"}},
+		{name: "query since", key: "queries.slow.since", values: []string{"90m"}, want: []string{"1h30m0s"}},
+		{name: "invalid query limit", key: "queries.slow.limit", values: []string{"-1"}, wantErr: true},
+		{name: "unsupported key", key: "color", values: []string{"red"}, wantErr: true},
+		{name: "unsupported query key", key: "queries.slow.root", values: []string{"/api"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{}
+			err := p.Set(tt.key, tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := p.Get(tt.key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gtrace", "config.yaml")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load missing config: %v", err)
+	}
+
+	cfg.Profiles = map[string]*Profile{DefaultProfile: {}}
+	p := cfg.Profile(DefaultProfile)
+	for key, values := range map[string][]string{
+		"projects":            {"production-a,production-b"},
+		"format":              {"text"},
+		"queries.slow.filter": {"latency:500ms"},
+		"queries.slow.since":  {"6h"},
+		"queries.slow.limit":  {"200"},
+	} {
+		if err = p.Set(key, values); err != nil {
+			t.Fatalf("failed to set %s: %v", key, err)
+		}
+	}
+	if err = cfg.Save(path); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Errorf("Load() = %+v, want %+v", loaded.Profile(DefaultProfile), p)
+	}
+	if got := loaded.Profile(DefaultProfile).Queries["slow"].Since; got != 6*time.Hour {
+		t.Errorf("since = %v, want 6h", got)
+	}
+
+	wantKeys := []string{"projects", "format", "queries.slow.filter", "queries.slow.since", "queries.slow.limit"}
+	if got := loaded.Profile(DefaultProfile).Keys(); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("Keys() = %v, want %v", got, wantKeys)
+	}
+}
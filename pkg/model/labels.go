@@ -0,0 +1,110 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Well-known v1 labels the span status is derived from.
+const (
+	HTTPStatusCodeLabel = "/http/status_code"
+	ErrorMessageLabel   = "/error/message"
+	ErrorNameLabel      = "/error/name"
+)
+
+// Labels holding the gRPC status code and message of a span, which gtrace sets when flattening spans that
+// carry an explicit status, such as v2 spans, into v1 spans.
+const (
+	StatusCodeLabel    = "/status/code"
+	StatusMessageLabel = "/status/message"
+)
+
+// MinHTTPErrorStatus is the lowest HTTP status code of a failed span. Client errors are the caller's fault and
+// leave the span status OK.
+const MinHTTPErrorStatus = 500
+
+// ErrorLabels mark a span as failed when set, see LabelSet.
+var ErrorLabels = []string{"error", ErrorMessageLabel, ErrorNameLabel, "/stacktrace"}
+
+// StatusCodeLabels hold gRPC status codes, by number or by name, any code but OK marks a span as failed.
+var StatusCodeLabels = []string{StatusCodeLabel, "/rpc/status_code", "rpc.grpc.status_code"}
+
+// LabelSet reports whether a flag-like label value is set, i.e. anything but empty, "false" or "0".
+func LabelSet(value string) bool {
+	return value != "" && value != "false" && value != "0"
+}
+
+var codeNames = func() map[string]codes.Code {
+	names := map[string]codes.Code{"CANCELLED": codes.Canceled}
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		names[strings.ToUpper(code.String())] = code
+	}
+	return names
+}()
+
+// ParseCode parses a gRPC status code given by number, such as "14", or by name, such as "UNAVAILABLE" or
+// "Unavailable". Numbers out of the gRPC range, such as HTTP status codes, are not codes.
+func ParseCode(value string) (codes.Code, bool) {
+	if code, err := strconv.Atoi(value); err == nil {
+		return codes.Code(code), code >= 0 && code <= int(codes.Unauthenticated)
+	}
+	code, found := codeNames[strings.ToUpper(strings.ReplaceAll(value, "_", ""))]
+	return code, found
+}
+
+// LabelStatus derives the status of a span from its v1 labels, since v1 has no status field. The status label
+// set by gtrace wins, otherwise any failure told by the status code, HTTP status code or error labels does,
+// the same way the default error rules classify spans. It returns nil if no label tells.
+func LabelStatus(labels map[string]string) *Status {
+	message := labels[ErrorMessageLabel]
+	if message == "" {
+		message = labels[ErrorNameLabel]
+	}
+
+	told := false
+	for _, key := range StatusCodeLabels {
+		code, found := ParseCode(labels[key])
+		if !found {
+			continue
+		}
+		if key == StatusCodeLabel {
+			return &Status{Code: int32(code), Message: labels[StatusMessageLabel]}
+		}
+		if code != codes.OK {
+			return &Status{Code: int32(code), Message: message}
+		}
+		told = true
+	}
+	if code, err := strconv.Atoi(labels[HTTPStatusCodeLabel]); err == nil {
+		if code >= MinHTTPErrorStatus {
+			return &Status{Code: int32(httpToCode(code)), Message: message}
+		}
+		told = true
+	}
+	for _, key := range ErrorLabels {
+		if LabelSet(labels[key]) {
+			return &Status{Code: int32(codes.Unknown), Message: message}
+		}
+	}
+	if told {
+		return &Status{}
+	}
+	return nil
+}
+
+func httpToCode(code int) codes.Code {
+	switch {
+	case code == 501:
+		return codes.Unimplemented
+	case code == 503:
+		return codes.Unavailable
+	case code == 504:
+		return codes.DeadlineExceeded
+	case code >= 500 && code < 600:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		value string
+		want  codes.Code
+		found bool
+	}{
+		{value: "0", want: codes.OK, found: true},
+		{value: "14", want: codes.Unavailable, found: true},
+		{value: "16", want: codes.Unauthenticated, found: true},
+		{value: "UNAVAILABLE", want: codes.Unavailable, found: true},
+		{value: "DeadlineExceeded", want: codes.DeadlineExceeded, found: true},
+		{value: "DEADLINE_EXCEEDED", want: codes.DeadlineExceeded, found: true},
+		{value: "CANCELLED", want: codes.Canceled, found: true},
+		{value: "17"},
+		{value: "200"},
+		{value: "404"},
+		{value: "-1"},
+		{value: "boom"},
+		{value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, found := ParseCode(tt.value)
+			if found != tt.found || (found && got != tt.want) {
+				t.Fatalf("ParseCode(%q)=%v, %v want: %v, %v", tt.value, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package model
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Kind int
+
+const (
+	KindUnspecified Kind = iota
+	KindInternal
+	KindServer
+	KindClient
+	KindProducer
+	KindConsumer
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindInternal:
+		return "INTERNAL"
+	case KindServer:
+		return "SERVER"
+	case KindClient:
+		return "CLIENT"
+	case KindProducer:
+		return "PRODUCER"
+	case KindConsumer:
+		return "CONSUMER"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+type ValueType int
+
+const (
+	StringType ValueType = iota
+	IntType
+	BoolType
+)
+
+// Value is a typed attribute value.
+type Value struct {
+	Type ValueType
+	Str  string
+	Int  int64
+	Bool bool
+}
+
+func StringValue(s string) Value {
+	return Value{Type: StringType, Str: s}
+}
+
+func IntValue(i int64) Value {
+	return Value{Type: IntType, Int: i}
+}
+
+func BoolValue(b bool) Value {
+	return Value{Type: BoolType, Bool: b}
+}
+
+func (v Value) String() string {
+	switch v.Type {
+	case IntType:
+		return strconv.FormatInt(v.Int, 10)
+	case BoolType:
+		return strconv.FormatBool(v.Bool)
+	default:
+		return v.Str
+	}
+}
+
+// Status is the result of a span, with a gRPC status code.
+type Status struct {
+	Code    int32
+	Message string
+}
+
+func (s *Status) OK() bool {
+	return s == nil || s.Code == 0
+}
+
+type EventType int
+
+const (
+	EventAnnotation EventType = iota
+	EventSent
+	EventReceived
+)
+
+// Event is a time-stamped annotation or message event of a span.
+type Event struct {
+	Time             time.Time
+	Type             EventType
+	Description      string
+	Attributes       map[string]Value
+	MessageID        int64
+	UncompressedSize int64
+	CompressedSize   int64
+}
+
+type LinkType int
+
+const (
+	LinkUnspecified LinkType = iota
+	LinkChild
+	LinkParent
+)
+
+// Link points to a span, possibly of another trace.
+type Link struct {
+	TraceID    string
+	SpanID     uint64
+	Type       LinkType
+	Attributes map[string]Value
+}
+
+// Span is a format-neutral span. Parent, Children and Depth are set when the span is part of a Trace, Children
+// ordered by their start time and Depth being -1 for spans not reachable from a root or an orphan.
+type Span struct {
+	ID         uint64
+	ParentID   uint64
+	Name       string
+	Kind       Kind
+	Start, End time.Time
+	Attributes map[string]Value
+	Status     *Status
+	Events     []Event
+	Links      []Link
+
+	Parent   *Span
+	Children []*Span
+	Depth    int
+}
+
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Timed reports whether the span has both a start and an end time.
+func (s *Span) Timed() bool {
+	return !s.Start.IsZero() && !s.End.IsZero()
+}
+
+// Attribute returns the string form of an attribute value, or an empty string if the span has no such attribute.
+func (s *Span) Attribute(key string) string {
+	v, found := s.Attributes[key]
+	if !found {
+		return ""
+	}
+	return v.String()
+}
+
+// Trace is a format-neutral trace with its spans indexed by id and linked to their parent and children.
+type Trace struct {
+	ProjectID string
+	TraceID   string
+	// Spans are all the spans of the trace, duplicates included, ordered by their start time.
+	Spans []*Span
+	// Roots are the spans without a parent, ordered by their start time.
+	Roots []*Span
+	// Orphans are the spans whose parent is missing, ordered by their start time.
+	Orphans []*Span
+	// Detached are the spans not reachable from any root or orphan, which happens when parent links form a cycle.
+	Detached []*Span
+	// Duplicates are the spans sharing an id with a previous span. They are not part of the tree.
+	Duplicates []*Span
+
+	byID map[uint64]*Span
+}
+
+// NewTrace builds a trace from spans given in any order, linking every span to its parent and children and
+// computing its depth. When spans share an id, the first one given is part of the tree. The slice is copied, but
+// the spans are owned by the trace afterwards, their links and depth being reset.
+func NewTrace(projectID, traceID string, spans []*Span) *Trace {
+	t := &Trace{
+		ProjectID: projectID,
+		TraceID:   traceID,
+		Spans:     slices.Clone(spans),
+		byID:      make(map[uint64]*Span, len(spans)),
+	}
+	duplicates := make(map[*Span]struct{})
+	for _, s := range spans {
+		s.Parent, s.Children, s.Depth = nil, nil, -1
+		if _, found := t.byID[s.ID]; found {
+			duplicates[s] = struct{}{}
+			continue
+		}
+		t.byID[s.ID] = s
+	}
+	sort.SliceStable(t.Spans, func(i, j int) bool {
+		return t.Spans[i].Start.Before(t.Spans[j].Start)
+	})
+
+	for _, s := range t.Spans {
+		if _, found := duplicates[s]; found {
+			t.Duplicates = append(t.Duplicates, s)
+			continue
+		}
+		if s.ParentID == 0 {
+			t.Roots = append(t.Roots, s)
+			continue
+		}
+		parent, found := t.byID[s.ParentID]
+		if !found {
+			t.Orphans = append(t.Orphans, s)
+			continue
+		}
+		// a span being its own parent is detached rather than linked into a cycle.
+		if parent == s {
+			continue
+		}
+		s.Parent = parent
+		parent.Children = append(parent.Children, s)
+	}
+
+	t.BFS(func(s *Span) bool {
+		s.Depth = 0
+		if s.Parent != nil {
+			s.Depth = s.Parent.Depth + 1
+		}
+		return true
+	})
+	for _, s := range t.Spans {
+		if _, found := duplicates[s]; !found && s.Depth < 0 {
+			t.Detached = append(t.Detached, s)
+		}
+	}
+	return t
+}
+
+// Span returns the span with the given id, or nil if the trace has no such span.
+func (t *Trace) Span(id uint64) *Span {
+	return t.byID[id]
+}
+
+// Len returns the number of spans in the tree, duplicates excluded.
+func (t *Trace) Len() int {
+	return len(t.byID)
+}
+
+// Interval returns the earliest start time and the latest end time among the trace spans.
+func (t *Trace) Interval() (start, end time.Time) {
+	for _, s := range t.Spans {
+		if !s.Start.IsZero() && (start.IsZero() || s.Start.Before(start)) {
+			start = s.Start
+		}
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+	return start, end
+}
+
+// Root returns the root that started first, or the first span if every span has a parent.
+func (t *Trace) Root() *Span {
+	if len(t.Roots) > 0 {
+		return t.Roots[0]
+	}
+	if len(t.Spans) > 0 {
+		return t.Spans[0]
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	tracev2 "cloud.google.com/go/trace/apiv2/tracepb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestFromV1(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *timestamppb.Timestamp {
+		return timestamppb.New(start.Add(offset))
+	}
+
+	// children appear before their parents and one span points to a missing parent.
+	trace := FromV1(&cloudtrace.Trace{
+		ProjectId: "project",
+		TraceId:   "abc",
+		Spans: []*cloudtrace.TraceSpan{
+			{SpanId: 3, ParentSpanId: 2, Name: "grandchild", StartTime: at(2 * time.Millisecond), EndTime: at(3 * time.Millisecond)},
+			{SpanId: 2, ParentSpanId: 1, Name: "child", StartTime: at(time.Millisecond), EndTime: at(4 * time.Millisecond),
+				Kind: cloudtrace.TraceSpan_RPC_CLIENT, Labels: map[string]string{"/http/status_code": "503", "zip": "007"}},
+			{SpanId: 1, Name: "root", StartTime: at(0), EndTime: at(5 * time.Millisecond)},
+			{SpanId: 4, ParentSpanId: 99, Name: "orphan", StartTime: at(time.Millisecond), EndTime: at(2 * time.Millisecond)},
+		},
+	})
+
+	if trace.Spans[0].Name != "root" {
+		t.Fatalf("spans are not ordered by start time, first span: %q", trace.Spans[0].Name)
+	}
+	if len(trace.Roots) != 1 || len(trace.Orphans) != 1 {
+		t.Fatalf("unexpected roots %d and orphans %d", len(trace.Roots), len(trace.Orphans))
+	}
+	grandchild := trace.Span(3)
+	if grandchild.Depth != 2 || grandchild.Parent != trace.Span(2) || grandchild.Parent.Parent != trace.Span(1) {
+		t.Fatalf("grandchild is not linked to its ancestors")
+	}
+	if len(trace.Span(1).Children) != 1 {
+		t.Fatalf("unexpected number of root children: %d", len(trace.Span(1).Children))
+	}
+
+	child := trace.Span(2)
+	if child.Kind != KindClient {
+		t.Fatalf("unexpected kind: %v", child.Kind)
+	}
+	if v := child.Attributes["/http/status_code"]; v.Type != StringType || v.Str != "503" {
+		t.Fatalf("status code attribute is not a string: %+v", v)
+	}
+	if v := child.Attributes["zip"]; v.Type != StringType || v.Str != "007" {
+		t.Fatalf("zip attribute lost its leading zeros: %+v", v)
+	}
+	if child.Status.OK() {
+		t.Fatalf("child status was not derived from its labels")
+	}
+	if child.Duration() != 3*time.Millisecond {
+		t.Fatalf("unexpected duration: %s", child.Duration())
+	}
+}
+
+func TestV2RoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	root := &Span{ID: 1, Name: "root", Kind: KindServer, Start: start, End: start.Add(time.Second),
+		Status: &Status{Code: 13, Message: "boom"},
+		Events: []Event{{Time: start, Type: EventAnnotation, Description: "retrying"}},
+		Links:  []Link{{TraceID: "def", SpanID: 7, Type: LinkParent}},
+	}
+	child := &Span{ID: 2, ParentID: 1, Name: "child", Start: start, End: start.Add(time.Millisecond),
+		Attributes: map[string]Value{"rows": IntValue(3)}}
+	spans := NewTrace("project", "abc", []*Span{root, child}).V2()
+
+	if spans[0].GetName() != "projects/project/traces/abc/spans/0000000000000001" {
+		t.Fatalf("unexpected span name: %q", spans[0].GetName())
+	}
+	if spans[0].GetSpanKind() != tracev2.Span_SERVER {
+		t.Fatalf("unexpected span kind: %v", spans[0].GetSpanKind())
+	}
+
+	traces, err := FromV2(spans)
+	if err != nil {
+		t.Fatalf("failed to convert v2 spans: %v", err)
+	}
+	if len(traces) != 1 {
+		t.Fatalf("unexpected number of traces: %d", len(traces))
+	}
+	got := traces[0]
+	if got.ProjectID != "project" || got.TraceID != "abc" {
+		t.Fatalf("unexpected trace: %s/%s", got.ProjectID, got.TraceID)
+	}
+	gotRoot := got.Span(1)
+	if gotRoot.Status == nil || gotRoot.Status.Code != 13 || gotRoot.Status.Message != "boom" {
+		t.Fatalf("status was lost: %+v", gotRoot.Status)
+	}
+	if len(gotRoot.Events) != 1 || gotRoot.Events[0].Description != "retrying" {
+		t.Fatalf("events were lost: %+v", gotRoot.Events)
+	}
+	if len(gotRoot.Links) != 1 || gotRoot.Links[0].SpanID != 7 || gotRoot.Links[0].Type != LinkParent {
+		t.Fatalf("links were lost: %+v", gotRoot.Links)
+	}
+	if got.Span(2).Parent != gotRoot || got.Span(2).Attributes["rows"].Int != 3 {
+		t.Fatalf("child was not restored")
+	}
+
+	v1 := got.V1()
+	if v1.GetSpans()[0].GetLabels()["/status/code"] != "13" {
+		t.Fatalf("status was not flattened into labels: %v", v1.GetSpans()[0].GetLabels())
+	}
+	if FromV1(v1).Span(1).Status.Code != 13 {
+		t.Fatalf("status did not survive the v1 round trip")
+	}
+}
@@ -0,0 +1,92 @@
+package model
+
+// Tops returns the roots followed by the orphans, the spans every reachable span descends from.
+func (t *Trace) Tops() []*Span {
+	tops := make([]*Span, 0, len(t.Roots)+len(t.Orphans))
+	tops = append(tops, t.Roots...)
+	return append(tops, t.Orphans...)
+}
+
+// DFS visits all the reachable spans in depth-first pre-order, tops first. Returning false from fn skips the
+// span descendants.
+func (t *Trace) DFS(fn func(*Span) bool) {
+	seen := make(map[*Span]struct{}, len(t.byID))
+	for _, top := range t.Tops() {
+		walk(top, fn, seen)
+	}
+}
+
+// BFS visits all the reachable spans in breadth-first order, tops first. Returning false from fn skips the
+// span descendants.
+func (t *Trace) BFS(fn func(*Span) bool) {
+	seen := make(map[*Span]struct{}, len(t.byID))
+	queue := t.Tops()
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if _, found := seen[s]; found {
+			continue
+		}
+		seen[s] = struct{}{}
+		if fn(s) {
+			queue = append(queue, s.Children...)
+		}
+	}
+}
+
+// Walk visits s and its descendants in depth-first pre-order. Returning false from fn skips the span descendants.
+// Every span is visited once, even if parent links form a cycle.
+func Walk(s *Span, fn func(*Span) bool) {
+	walk(s, fn, make(map[*Span]struct{}))
+}
+
+func walk(s *Span, fn func(*Span) bool, seen map[*Span]struct{}) {
+	if _, found := seen[s]; found {
+		return
+	}
+	seen[s] = struct{}{}
+	if !fn(s) {
+		return
+	}
+	for _, child := range s.Children {
+		walk(child, fn, seen)
+	}
+}
+
+// Ancestors returns the ancestors of the given span, its parent first. It is nil for unknown spans.
+func (t *Trace) Ancestors(id uint64) []*Span {
+	if t.byID[id] == nil {
+		return nil
+	}
+	return t.PathToRoot(id)[1:]
+}
+
+// PathToRoot returns the given span followed by its ancestors up to the top of the tree. Walking up stops
+// if the parent links form a cycle.
+func (t *Trace) PathToRoot(id uint64) []*Span {
+	var path []*Span
+	seen := make(map[*Span]struct{})
+	for s := t.byID[id]; s != nil; s = s.Parent {
+		if _, found := seen[s]; found {
+			break
+		}
+		seen[s] = struct{}{}
+		path = append(path, s)
+	}
+	return path
+}
+
+// LCA returns the lowest common ancestor of the two spans, which may be one of them. It returns nil if the spans
+// are unknown or do not share an ancestor.
+func (t *Trace) LCA(a, b uint64) *Span {
+	ancestors := make(map[*Span]struct{})
+	for _, s := range t.PathToRoot(a) {
+		ancestors[s] = struct{}{}
+	}
+	for _, s := range t.PathToRoot(b) {
+		if _, found := ancestors[s]; found {
+			return s
+		}
+	}
+	return nil
+}
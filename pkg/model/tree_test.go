@@ -0,0 +1,157 @@
+package model
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// testTrace returns a trace built from unordered spans:
+//
+//	1
+//	├── 2
+//	│   ├── 4
+//	│   └── 5
+//	└── 3
+//	    └── 6
+//	8 (orphan, parent 7 is missing)
+func testTrace() *Trace {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newSpan := func(id, parent uint64) *Span {
+		return &Span{ID: id, ParentID: parent, Start: start.Add(time.Duration(id) * time.Millisecond), End: start.Add(time.Second)}
+	}
+	return NewTrace("project", "abc", []*Span{
+		newSpan(6, 3), newSpan(4, 2), newSpan(2, 1), newSpan(8, 7), newSpan(5, 2), newSpan(1, 0), newSpan(3, 1),
+	})
+}
+
+func ids(spans []*Span) []uint64 {
+	var results []uint64
+	for _, s := range spans {
+		results = append(results, s.ID)
+	}
+	return results
+}
+
+func TestTree(t *testing.T) {
+	trace := testTrace()
+
+	if got := ids(trace.Roots); !slices.Equal(got, []uint64{1}) {
+		t.Fatalf("Roots=%v want: [1]", got)
+	}
+	if got := ids(trace.Orphans); !slices.Equal(got, []uint64{8}) {
+		t.Fatalf("Orphans=%v want: [8]", got)
+	}
+	if got := ids(trace.Span(2).Children); !slices.Equal(got, []uint64{4, 5}) {
+		t.Fatalf("Children(2)=%v want: [4 5]", got)
+	}
+	if depth := trace.Span(6).Depth; depth != 2 {
+		t.Fatalf("Depth(6)=%d want: 2", depth)
+	}
+	if got := ids(trace.PathToRoot(5)); !slices.Equal(got, []uint64{5, 2, 1}) {
+		t.Fatalf("PathToRoot(5)=%v want: [5 2 1]", got)
+	}
+	if got := ids(trace.Ancestors(5)); !slices.Equal(got, []uint64{2, 1}) {
+		t.Fatalf("Ancestors(5)=%v want: [2 1]", got)
+	}
+
+	var dfs, bfs []uint64
+	trace.DFS(func(s *Span) bool {
+		dfs = append(dfs, s.ID)
+		return true
+	})
+	trace.BFS(func(s *Span) bool {
+		bfs = append(bfs, s.ID)
+		return true
+	})
+	if !slices.Equal(dfs, []uint64{1, 2, 4, 5, 3, 6, 8}) {
+		t.Fatalf("DFS=%v", dfs)
+	}
+	if !slices.Equal(bfs, []uint64{1, 8, 2, 3, 4, 5, 6}) {
+		t.Fatalf("BFS=%v", bfs)
+	}
+
+	lcaTests := []struct {
+		a, b uint64
+		want uint64
+	}{
+		{a: 4, b: 5, want: 2},
+		{a: 4, b: 6, want: 1},
+		{a: 2, b: 5, want: 2},
+		{a: 4, b: 8, want: 0},
+	}
+	for _, tt := range lcaTests {
+		got := trace.LCA(tt.a, tt.b)
+		if tt.want == 0 {
+			if got != nil {
+				t.Fatalf("LCA(%d, %d)=%d want: nil", tt.a, tt.b, got.ID)
+			}
+			continue
+		}
+		if got == nil || got.ID != tt.want {
+			t.Fatalf("LCA(%d, %d)=%v want: %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTreeCycle(t *testing.T) {
+	trace := NewTrace("project", "abc", []*Span{
+		{ID: 1},
+		{ID: 2, ParentID: 3},
+		{ID: 3, ParentID: 2},
+		{ID: 3, ParentID: 1},
+	})
+	if len(trace.Detached) != 2 {
+		t.Fatalf("unexpected number of detached spans: %d", len(trace.Detached))
+	}
+	if len(trace.Duplicates) != 1 {
+		t.Fatalf("unexpected number of duplicates: %d", len(trace.Duplicates))
+	}
+	if got := ids(trace.PathToRoot(2)); !slices.Equal(got, []uint64{2, 3}) {
+		t.Fatalf("PathToRoot(2)=%v want: [2 3]", got)
+	}
+}
+
+func TestNewTraceKeepsInput(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	spans := []*Span{{ID: 2, ParentID: 1, Start: start.Add(time.Millisecond)}, {ID: 1, Start: start}}
+	trace := NewTrace("project", "abc", spans)
+	if got := ids(spans); !slices.Equal(got, []uint64{2, 1}) {
+		t.Fatalf("input spans were reordered: %v", got)
+	}
+	if got := ids(trace.Spans); !slices.Equal(got, []uint64{1, 2}) {
+		t.Fatalf("Spans=%v want: [1 2]", got)
+	}
+}
+
+func TestTreeSelfParent(t *testing.T) {
+	trace := NewTrace("project", "abc", []*Span{{ID: 1}, {ID: 2, ParentID: 2}, {ID: 3, ParentID: 2}})
+	if got := ids(trace.Detached); !slices.Equal(got, []uint64{2, 3}) {
+		t.Fatalf("Detached=%v want: [2 3]", got)
+	}
+	if got := ids(trace.Span(2).Children); !slices.Equal(got, []uint64{3}) {
+		t.Fatalf("Children(2)=%v want: [3]", got)
+	}
+}
+
+func TestWalkCycle(t *testing.T) {
+	trace := NewTrace("project", "abc", []*Span{{ID: 1}, {ID: 2, ParentID: 3}, {ID: 3, ParentID: 2}, {ID: 4, ParentID: 4}})
+	tests := []struct {
+		root uint64
+		want []uint64
+	}{
+		{root: 2, want: []uint64{2, 3}},
+		{root: 3, want: []uint64{3, 2}},
+		{root: 4, want: []uint64{4}},
+	}
+	for _, tt := range tests {
+		var got []uint64
+		Walk(trace.Span(tt.root), func(s *Span) bool {
+			got = append(got, s.ID)
+			return true
+		})
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("Walk(%d)=%v want: %v", tt.root, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"strconv"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FromV1 converts a v1 trace. Labels become string attributes, as typing them would lose values such as "007",
+// and the status is derived from the labels.
+func FromV1(trace *cloudtrace.Trace) *Trace {
+	spans := make([]*Span, 0, len(trace.GetSpans()))
+	for _, s := range trace.GetSpans() {
+		spans = append(spans, FromV1Span(s))
+	}
+	return NewTrace(trace.GetProjectId(), trace.GetTraceId(), spans)
+}
+
+// FromV1Span converts a v1 span, as FromV1 does, leaving it unlinked.
+func FromV1Span(s *cloudtrace.TraceSpan) *Span {
+	result := &Span{
+		ID:       s.GetSpanId(),
+		ParentID: s.GetParentSpanId(),
+		Name:     s.GetName(),
+	}
+	switch s.GetKind() {
+	case cloudtrace.TraceSpan_RPC_SERVER:
+		result.Kind = KindServer
+	case cloudtrace.TraceSpan_RPC_CLIENT:
+		result.Kind = KindClient
+	}
+	if s.GetStartTime().IsValid() {
+		result.Start = s.GetStartTime().AsTime()
+	}
+	if s.GetEndTime().IsValid() {
+		result.End = s.GetEndTime().AsTime()
+	}
+	if len(s.GetLabels()) > 0 {
+		result.Attributes = make(map[string]Value, len(s.GetLabels()))
+		for key, value := range s.GetLabels() {
+			result.Attributes[key] = StringValue(value)
+		}
+	}
+	result.Status = LabelStatus(s.GetLabels())
+	return result
+}
+
+// V1 converts the trace into a v1 trace. Attributes become labels and the status is kept as status labels,
+// while events and links, which v1 cannot carry, are dropped.
+func (t *Trace) V1() *cloudtrace.Trace {
+	result := &cloudtrace.Trace{
+		ProjectId: t.ProjectID,
+		TraceId:   t.TraceID,
+		Spans:     make([]*cloudtrace.TraceSpan, 0, len(t.Spans)),
+	}
+	for _, s := range t.Spans {
+		result.Spans = append(result.Spans, s.V1())
+	}
+	return result
+}
+
+// V1 converts the span into a v1 span, as Trace.V1 does.
+func (s *Span) V1() *cloudtrace.TraceSpan {
+	v1 := &cloudtrace.TraceSpan{
+		SpanId:       s.ID,
+		ParentSpanId: s.ParentID,
+		Name:         s.Name,
+	}
+	switch s.Kind {
+	case KindServer:
+		v1.Kind = cloudtrace.TraceSpan_RPC_SERVER
+	case KindClient:
+		v1.Kind = cloudtrace.TraceSpan_RPC_CLIENT
+	}
+	if !s.Start.IsZero() {
+		v1.StartTime = timestamppb.New(s.Start)
+	}
+	if !s.End.IsZero() {
+		v1.EndTime = timestamppb.New(s.End)
+	}
+
+	if labels := s.Labels(); len(labels) > 0 {
+		v1.Labels = labels
+	}
+	return v1
+}
+
+// Labels returns the attributes of the span as v1 labels. The status is added as status labels when the other
+// labels do not already derive the same status, so that the labels alone tell whether the span failed.
+func (s *Span) Labels() map[string]string {
+	labels := make(map[string]string, len(s.Attributes)+2)
+	for key, value := range s.Attributes {
+		labels[key] = value.String()
+	}
+	if derived := LabelStatus(labels); s.Status != nil && (derived == nil || *derived != *s.Status) {
+		if _, found := labels[StatusCodeLabel]; !found {
+			labels[StatusCodeLabel] = strconv.Itoa(int(s.Status.Code))
+		}
+		if _, found := labels[StatusMessageLabel]; !found && s.Status.Message != "" {
+			labels[StatusMessageLabel] = s.Status.Message
+		}
+	}
+	return labels
+}
@@ -0,0 +1,257 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tracev2 "cloud.google.com/go/trace/apiv2/tracepb"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// V2Name returns the resource name of a v2 span.
+func V2Name(projectID, traceID, spanID string) string {
+	return fmt.Sprintf("projects/%s/traces/%s/spans/%s", projectID, traceID, spanID)
+}
+
+// ParseV2Name splits the resource name of a v2 span into its project, trace and span ids.
+func ParseV2Name(name string) (projectID, traceID, spanID string, err error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "traces" || parts[4] != "spans" {
+		return "", "", "", fmt.Errorf("invalid span name %q", name)
+	}
+	return parts[1], parts[3], parts[5], nil
+}
+
+// V2SpanID formats a v1 span id as a v2 span id, a 16 characters hex string.
+func V2SpanID(id uint64) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%016x", id)
+}
+
+// FromV2 converts v2 spans, grouping them into traces by the trace id of their resource name.
+func FromV2(spans []*tracev2.Span) ([]*Trace, error) {
+	type key struct{ project, trace string }
+	var order []key
+	grouped := make(map[key][]*Span)
+
+	for _, s := range spans {
+		projectID, traceID, spanID, err := ParseV2Name(s.GetName())
+		if err != nil {
+			return nil, err
+		}
+		result := &Span{
+			Name:       s.GetDisplayName().GetValue(),
+			Attributes: fromV2Attributes(s.GetAttributes()),
+		}
+		if result.ID, err = parseV2SpanID(spanID); err != nil {
+			return nil, err
+		}
+		if result.ParentID, err = parseV2SpanID(s.GetParentSpanId()); err != nil {
+			return nil, err
+		}
+		switch s.GetSpanKind() {
+		case tracev2.Span_INTERNAL:
+			result.Kind = KindInternal
+		case tracev2.Span_SERVER:
+			result.Kind = KindServer
+		case tracev2.Span_CLIENT:
+			result.Kind = KindClient
+		case tracev2.Span_PRODUCER:
+			result.Kind = KindProducer
+		case tracev2.Span_CONSUMER:
+			result.Kind = KindConsumer
+		}
+		if s.GetStartTime().IsValid() {
+			result.Start = s.GetStartTime().AsTime()
+		}
+		if s.GetEndTime().IsValid() {
+			result.End = s.GetEndTime().AsTime()
+		}
+		if s.GetStatus() != nil {
+			result.Status = &Status{Code: s.GetStatus().GetCode(), Message: s.GetStatus().GetMessage()}
+		}
+
+		for _, e := range s.GetTimeEvents().GetTimeEvent() {
+			event := Event{Time: e.GetTime().AsTime()}
+			if a := e.GetAnnotation(); a != nil {
+				event.Type = EventAnnotation
+				event.Description = a.GetDescription().GetValue()
+				event.Attributes = fromV2Attributes(a.GetAttributes())
+			}
+			if m := e.GetMessageEvent(); m != nil {
+				event.Type = EventSent
+				if m.GetType() == tracev2.Span_TimeEvent_MessageEvent_RECEIVED {
+					event.Type = EventReceived
+				}
+				event.MessageID = m.GetId()
+				event.UncompressedSize = m.GetUncompressedSizeBytes()
+				event.CompressedSize = m.GetCompressedSizeBytes()
+			}
+			result.Events = append(result.Events, event)
+		}
+
+		for _, l := range s.GetLinks().GetLink() {
+			link := Link{TraceID: l.GetTraceId(), Attributes: fromV2Attributes(l.GetAttributes())}
+			if link.SpanID, err = parseV2SpanID(l.GetSpanId()); err != nil {
+				return nil, err
+			}
+			switch l.GetType() {
+			case tracev2.Span_Link_CHILD_LINKED_SPAN:
+				link.Type = LinkChild
+			case tracev2.Span_Link_PARENT_LINKED_SPAN:
+				link.Type = LinkParent
+			}
+			result.Links = append(result.Links, link)
+		}
+
+		k := key{project: projectID, trace: traceID}
+		if _, found := grouped[k]; !found {
+			order = append(order, k)
+		}
+		grouped[k] = append(grouped[k], result)
+	}
+
+	traces := make([]*Trace, 0, len(order))
+	for _, k := range order {
+		traces = append(traces, NewTrace(k.project, k.trace, grouped[k]))
+	}
+	return traces, nil
+}
+
+// V2 converts the trace into v2 spans.
+func (t *Trace) V2() []*tracev2.Span {
+	results := make([]*tracev2.Span, 0, len(t.Spans))
+	for _, s := range t.Spans {
+		spanID := V2SpanID(s.ID)
+		result := &tracev2.Span{
+			Name:         V2Name(t.ProjectID, t.TraceID, spanID),
+			SpanId:       spanID,
+			ParentSpanId: V2SpanID(s.ParentID),
+			DisplayName:  truncatable(s.Name),
+			Attributes:   toV2Attributes(s.Attributes),
+		}
+		switch s.Kind {
+		case KindInternal:
+			result.SpanKind = tracev2.Span_INTERNAL
+		case KindServer:
+			result.SpanKind = tracev2.Span_SERVER
+		case KindClient:
+			result.SpanKind = tracev2.Span_CLIENT
+		case KindProducer:
+			result.SpanKind = tracev2.Span_PRODUCER
+		case KindConsumer:
+			result.SpanKind = tracev2.Span_CONSUMER
+		}
+		if !s.Start.IsZero() {
+			result.StartTime = timestamppb.New(s.Start)
+		}
+		if !s.End.IsZero() {
+			result.EndTime = timestamppb.New(s.End)
+		}
+		if s.Status != nil {
+			result.Status = &status.Status{Code: s.Status.Code, Message: s.Status.Message}
+		}
+
+		if len(s.Events) > 0 {
+			result.TimeEvents = &tracev2.Span_TimeEvents{}
+		}
+		for _, e := range s.Events {
+			event := &tracev2.Span_TimeEvent{Time: timestamppb.New(e.Time)}
+			if e.Type == EventAnnotation {
+				event.Value = &tracev2.Span_TimeEvent_Annotation_{Annotation: &tracev2.Span_TimeEvent_Annotation{
+					Description: truncatable(e.Description),
+					Attributes:  toV2Attributes(e.Attributes),
+				}}
+			} else {
+				messageType := tracev2.Span_TimeEvent_MessageEvent_SENT
+				if e.Type == EventReceived {
+					messageType = tracev2.Span_TimeEvent_MessageEvent_RECEIVED
+				}
+				event.Value = &tracev2.Span_TimeEvent_MessageEvent_{MessageEvent: &tracev2.Span_TimeEvent_MessageEvent{
+					Type:                  messageType,
+					Id:                    e.MessageID,
+					UncompressedSizeBytes: e.UncompressedSize,
+					CompressedSizeBytes:   e.CompressedSize,
+				}}
+			}
+			result.TimeEvents.TimeEvent = append(result.TimeEvents.TimeEvent, event)
+		}
+
+		if len(s.Links) > 0 {
+			result.Links = &tracev2.Span_Links{}
+		}
+		for _, l := range s.Links {
+			link := &tracev2.Span_Link{
+				TraceId:    l.TraceID,
+				SpanId:     V2SpanID(l.SpanID),
+				Attributes: toV2Attributes(l.Attributes),
+			}
+			switch l.Type {
+			case LinkChild:
+				link.Type = tracev2.Span_Link_CHILD_LINKED_SPAN
+			case LinkParent:
+				link.Type = tracev2.Span_Link_PARENT_LINKED_SPAN
+			}
+			result.Links.Link = append(result.Links.Link, link)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func parseV2SpanID(id string) (uint64, error) {
+	if id == "" {
+		return 0, nil
+	}
+	result, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid span id %q: %w", id, err)
+	}
+	return result, nil
+}
+
+func truncatable(value string) *tracev2.TruncatableString {
+	return &tracev2.TruncatableString{Value: value}
+}
+
+func fromV2Attributes(attrs *tracev2.Span_Attributes) map[string]Value {
+	if len(attrs.GetAttributeMap()) == 0 {
+		return nil
+	}
+	results := make(map[string]Value, len(attrs.GetAttributeMap()))
+	for key, value := range attrs.GetAttributeMap() {
+		switch v := value.GetValue().(type) {
+		case *tracev2.AttributeValue_IntValue:
+			results[key] = IntValue(v.IntValue)
+		case *tracev2.AttributeValue_BoolValue:
+			results[key] = BoolValue(v.BoolValue)
+		case *tracev2.AttributeValue_StringValue:
+			results[key] = StringValue(v.StringValue.GetValue())
+		}
+	}
+	return results
+}
+
+func toV2Attributes(attrs map[string]Value) *tracev2.Span_Attributes {
+	if len(attrs) == 0 {
+		return nil
+	}
+	results := &tracev2.Span_Attributes{AttributeMap: make(map[string]*tracev2.AttributeValue, len(attrs))}
+	for key, value := range attrs {
+		var v *tracev2.AttributeValue
+		switch value.Type {
+		case IntType:
+			v = &tracev2.AttributeValue{Value: &tracev2.AttributeValue_IntValue{IntValue: value.Int}}
+		case BoolType:
+			v = &tracev2.AttributeValue{Value: &tracev2.AttributeValue_BoolValue{BoolValue: value.Bool}}
+		default:
+			v = &tracev2.AttributeValue{Value: &tracev2.AttributeValue_StringValue{StringValue: truncatable(value.Str)}}
+		}
+		results.AttributeMap[key] = v
+	}
+	return results
+}
@@ -0,0 +1,181 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/filter"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const hashPrefix = "hmac:"
+
+// URLLabels are the well-known labels holding URLs, whose query strings are scrubbed.
+var URLLabels = []string{"/http/url", "/http/redirected_url"}
+
+// Redactor rewrites traces so they can be shared. Identifiers and hashed label values are derived from a keyed hash,
+// so the same key always maps an input to the same output while not being reversible without the key.
+type Redactor struct {
+	key       []byte
+	drop      *filter.Filter
+	hash      *filter.Filter
+	keepQuery bool
+	shift     time.Duration
+}
+
+type Option func(r *Redactor)
+
+// WithKey sets the key of the identifiers and values hashing. Without it a random key is used.
+func WithKey(key []byte) Option {
+	return func(r *Redactor) {
+		r.key = key
+	}
+}
+
+// WithDropLabels removes the labels whose key passes the filter.
+func WithDropLabels(f *filter.Filter) Option {
+	return func(r *Redactor) {
+		r.drop = f
+	}
+}
+
+// WithHashLabels replaces the values of the labels whose key passes the filter by their hash.
+func WithHashLabels(f *filter.Filter) Option {
+	return func(r *Redactor) {
+		r.hash = f
+	}
+}
+
+// WithURLQuery keeps the query string of URL labels instead of scrubbing it.
+func WithURLQuery() Option {
+	return func(r *Redactor) {
+		r.keepQuery = true
+	}
+}
+
+// WithTimeShift moves all the span timestamps by the given duration.
+func WithTimeShift(shift time.Duration) Option {
+	return func(r *Redactor) {
+		r.shift = shift
+	}
+}
+
+func New(opts ...Option) (*Redactor, error) {
+	r := &Redactor{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if len(r.key) == 0 {
+		r.key = make([]byte, 32)
+		if _, err := rand.Read(r.key); err != nil {
+			return nil, fmt.Errorf("generate key: %w", err)
+		}
+	}
+	return r, nil
+}
+
+func (r *Redactor) sum(kind, value string) []byte {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// TraceID maps a trace id to a new 128-bit hex trace id.
+func (r *Redactor) TraceID(id string) string {
+	return hex.EncodeToString(r.sum("trace", id)[:16])
+}
+
+// SpanID maps a span id to a new non-zero span id. Zero, meaning no parent, is kept as is.
+func (r *Redactor) SpanID(id uint64) uint64 {
+	if id == 0 {
+		return 0
+	}
+	mapped := binary.BigEndian.Uint64(r.sum("span", fmt.Sprint(id)))
+	if mapped == 0 {
+		mapped = 1
+	}
+	return mapped
+}
+
+// Value returns the hash of a label value.
+func (r *Redactor) Value(value string) string {
+	return hashPrefix + hex.EncodeToString(r.sum("value", value)[:8])
+}
+
+// Trace returns a redacted copy of the trace.
+func (r *Redactor) Trace(trace *cloudtrace.Trace) *cloudtrace.Trace {
+	result := proto.Clone(trace).(*cloudtrace.Trace)
+	result.TraceId = r.TraceID(trace.GetTraceId())
+	for _, s := range result.Spans {
+		s.SpanId = r.SpanID(s.GetSpanId())
+		s.ParentSpanId = r.SpanID(s.GetParentSpanId())
+		s.Labels = r.Labels(s.GetLabels())
+		if r.shift != 0 {
+			if s.StartTime.IsValid() {
+				s.StartTime = timestamppb.New(s.StartTime.AsTime().Add(r.shift))
+			}
+			if s.EndTime.IsValid() {
+				s.EndTime = timestamppb.New(s.EndTime.AsTime().Add(r.shift))
+			}
+		}
+	}
+	return result
+}
+
+// Labels returns the redacted labels.
+func (r *Redactor) Labels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	result := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if r.drop != nil && r.drop.Pass(key) {
+			continue
+		}
+		if r.hash != nil && r.hash.Pass(key) {
+			result[key] = r.Value(value)
+			continue
+		}
+		if !r.keepQuery && isURLLabel(key) {
+			value = ScrubURL(value)
+		}
+		result[key] = value
+	}
+	return result
+}
+
+func isURLLabel(key string) bool {
+	for _, l := range URLLabels {
+		if key == l {
+			return true
+		}
+	}
+	return false
+}
+
+// ScrubURL removes the user information, query string and fragment of a URL.
+func ScrubURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		value, _, _ = strings.Cut(value, "?")
+		value, _, _ = strings.Cut(value, "#")
+		return value
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
@@ -0,0 +1,109 @@
+package span
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// DefaultRepetitionCap is the default number of repetitions of identical sibling subtrees above which shape
+// signatures no longer differ.
+const DefaultRepetitionCap = 3
+
+// Cluster is a group of traces of the same endpoint sharing a span tree shape.
+type Cluster struct {
+	ID       string `json:"id"`
+	Endpoint string `json:"endpoint"`
+	// Signature is the normalized shape of the span trees, such as "/api[cache,db*3+]".
+	Signature string        `json:"signature"`
+	Traces    []string      `json:"traces"`
+	Stats     DurationStats `json:"stats"`
+}
+
+// Signature returns the normalized shape of the span tree: span names and structure, with children ordered by
+// signature and identical sibling subtrees collapsed into a repetition count, where counts above repetitionCap are
+// all written as repetitionCap+. A repetitionCap of zero or less means DefaultRepetitionCap. Multiple tops, roots
+// and orphans, are separated by a '|'.
+func Signature(trace *model.Trace, repetitionCap int) string {
+	if repetitionCap <= 0 {
+		repetitionCap = DefaultRepetitionCap
+	}
+	return collapse(trace.Tops(), repetitionCap, "|")
+}
+
+func signature(s *model.Span, repetitionCap int) string {
+	if len(s.Children) == 0 {
+		return s.Name
+	}
+	return s.Name + "[" + collapse(s.Children, repetitionCap, ",") + "]"
+}
+
+func collapse(spans []*model.Span, repetitionCap int, sep string) string {
+	counts := make(map[string]int)
+	for _, s := range spans {
+		counts[signature(s, repetitionCap)]++
+	}
+	signatures := make([]string, 0, len(counts))
+	for s := range counts {
+		signatures = append(signatures, s)
+	}
+	sort.Strings(signatures)
+
+	parts := make([]string, 0, len(signatures))
+	for _, s := range signatures {
+		switch count := counts[s]; {
+		case count > repetitionCap:
+			parts = append(parts, fmt.Sprintf("%s*%d+", s, repetitionCap))
+		case count > 1:
+			parts = append(parts, fmt.Sprintf("%s*%d", s, count))
+		default:
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// Clusters groups the traces by root span name and shape signature, ordered by endpoint and descending number
+// of traces.
+func Clusters(traces []*model.Trace, repetitionCap int) []Cluster {
+	type key struct{ endpoint, signature string }
+	var order []key
+	grouped := make(map[key][]*model.Trace)
+	for _, t := range traces {
+		root := t.Root()
+		if root == nil {
+			continue
+		}
+		k := key{endpoint: root.Name, signature: Signature(t, repetitionCap)}
+		if _, found := grouped[k]; !found {
+			order = append(order, k)
+		}
+		grouped[k] = append(grouped[k], t)
+	}
+
+	results := make([]Cluster, 0, len(order))
+	for _, k := range order {
+		sum := sha256.Sum256([]byte(k.signature))
+		c := Cluster{ID: hex.EncodeToString(sum[:4]), Endpoint: k.endpoint, Signature: k.signature}
+		durations := make([]time.Duration, 0, len(grouped[k]))
+		for _, t := range grouped[k] {
+			c.Traces = append(c.Traces, t.TraceID)
+			start, end := t.Interval()
+			durations = append(durations, end.Sub(start))
+		}
+		c.Stats = NewDurationStats(durations)
+		results = append(results, c)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Endpoint != results[j].Endpoint {
+			return results[i].Endpoint < results[j].Endpoint
+		}
+		return results[i].Stats.Count > results[j].Stats.Count
+	})
+	return results
+}
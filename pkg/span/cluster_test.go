@@ -0,0 +1,52 @@
+package span
+
+import (
+	"testing"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+func TestSignature(t *testing.T) {
+	newTrace := func(queries int, cache bool) []*cloudtrace.TraceSpan {
+		spans := []*cloudtrace.TraceSpan{{SpanId: 1, Name: "/api"}}
+		for i := 0; i < queries; i++ {
+			spans = append(spans, &cloudtrace.TraceSpan{SpanId: uint64(10 + i), ParentSpanId: 1, Name: "db"})
+		}
+		if cache {
+			spans = append(spans,
+				&cloudtrace.TraceSpan{SpanId: 2, ParentSpanId: 1, Name: "cache"},
+				&cloudtrace.TraceSpan{SpanId: 3, ParentSpanId: 2, Name: "redis"})
+		}
+		return spans
+	}
+
+	tests := []struct {
+		name  string
+		spans []*cloudtrace.TraceSpan
+		cap   int
+		want  string
+	}{
+		{name: "single child", spans: newTrace(1, false), want: "/api[db]"},
+		{name: "nested", spans: newTrace(2, true), want: "/api[cache[redis],db*2]"},
+		{name: "capped", spans: newTrace(10, false), want: "/api[db*3+]"},
+		{name: "custom cap", spans: newTrace(10, false), cap: 5, want: "/api[db*5+]"},
+		{name: "orphan", spans: append(newTrace(1, false), &cloudtrace.TraceSpan{SpanId: 4, ParentSpanId: 99, Name: "late"}), want: "/api[db]|late"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Signature(fromV1(tt.spans), tt.cap); got != tt.want {
+				t.Fatalf("Signature()=%q want: %q", got, tt.want)
+			}
+		})
+	}
+
+	clusters := Clusters([]*model.Trace{
+		model.FromV1(&cloudtrace.Trace{TraceId: "a", Spans: newTrace(4, false)}),
+		model.FromV1(&cloudtrace.Trace{TraceId: "b", Spans: newTrace(1, true)}),
+		model.FromV1(&cloudtrace.Trace{TraceId: "c", Spans: newTrace(7, false)}),
+	}, 0)
+	if len(clusters) != 2 || clusters[0].Stats.Count != 2 || clusters[0].Traces[0] != "a" || clusters[0].Traces[1] != "c" {
+		t.Fatalf("Clusters()=%+v", clusters)
+	}
+}
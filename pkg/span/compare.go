@@ -0,0 +1,125 @@
+package span
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// Shift compares the durations of the spans of a name between a baseline and a current set of traces.
+type Shift struct {
+	Name     string        `json:"name"`
+	Baseline DurationStats `json:"baseline"`
+	Current  DurationStats `json:"current"`
+	P50Delta time.Duration `json:"p50Delta"`
+	P99Delta time.Duration `json:"p99Delta"`
+	// PValue is the two-sided Mann-Whitney U test p-value of the durations coming from the same distribution.
+	// It is 1 when either side has no spans.
+	PValue float64 `json:"pValue"`
+}
+
+// NameDurations returns the durations of the spans per name. Spans missing a timestamp have no duration and are
+// skipped.
+func NameDurations(spans []*model.Span) map[string][]time.Duration {
+	results := make(map[string][]time.Duration)
+	for _, s := range spans {
+		if !s.Timed() {
+			continue
+		}
+		results[s.Name] = append(results[s.Name], s.Duration())
+	}
+	return results
+}
+
+// Shifts compares the baseline and current durations of every span name found on either side, ordered by
+// descending absolute p50 delta, then p99 delta.
+func Shifts(baseline, current map[string][]time.Duration) []Shift {
+	names := make(map[string]struct{}, len(baseline))
+	for name := range baseline {
+		names[name] = struct{}{}
+	}
+	for name := range current {
+		names[name] = struct{}{}
+	}
+
+	results := make([]Shift, 0, len(names))
+	for name := range names {
+		s := Shift{
+			Name:     name,
+			Baseline: NewDurationStats(baseline[name]),
+			Current:  NewDurationStats(current[name]),
+			PValue:   1,
+		}
+		if s.Baseline.Count > 0 && s.Current.Count > 0 {
+			s.P50Delta = s.Current.P50 - s.Baseline.P50
+			s.P99Delta = s.Current.P99 - s.Baseline.P99
+			_, s.PValue = MannWhitney(baseline[name], current[name])
+		}
+		results = append(results, s)
+	}
+
+	abs := func(d time.Duration) time.Duration { return max(d, -d) }
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if abs(a.P50Delta) != abs(b.P50Delta) {
+			return abs(a.P50Delta) > abs(b.P50Delta)
+		}
+		if abs(a.P99Delta) != abs(b.P99Delta) {
+			return abs(a.P99Delta) > abs(b.P99Delta)
+		}
+		return a.Name < b.Name
+	})
+	return results
+}
+
+// MannWhitney runs the two-sided Mann-Whitney U test on two samples, using the normal approximation with tie and
+// continuity corrections. It returns the U statistic of the first sample and the p-value.
+func MannWhitney(a, b []time.Duration) (u, p float64) {
+	n1, n2 := len(a), len(b)
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	type sample struct {
+		value time.Duration
+		first bool
+	}
+	samples := make([]sample, 0, n1+n2)
+	for _, d := range a {
+		samples = append(samples, sample{value: d, first: true})
+	}
+	for _, d := range b {
+		samples = append(samples, sample{value: d})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].value < samples[j].value })
+
+	// Tied values share the average of their ranks.
+	var rankSum, ties float64
+	for i := 0; i < len(samples); {
+		j := i
+		for j < len(samples) && samples[j].value == samples[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if samples[k].first {
+				rankSum += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n := float64(n1 + n2)
+	u = rankSum - float64(n1*(n1+1))/2
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return u, 1
+	}
+	z := max(math.Abs(u-mean)-0.5, 0) / math.Sqrt(variance)
+	return u, math.Erfc(z / math.Sqrt2)
+}
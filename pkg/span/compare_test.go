@@ -0,0 +1,92 @@
+package span
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMannWhitney(t *testing.T) {
+	durations := func(values ...int) []time.Duration {
+		var results []time.Duration
+		for _, v := range values {
+			results = append(results, time.Duration(v)*time.Millisecond)
+		}
+		return results
+	}
+
+	tests := []struct {
+		name  string
+		a, b  []time.Duration
+		wantU float64
+		wantP float64
+	}{
+		{name: "identical", a: durations(1, 2, 3, 4, 5), b: durations(1, 2, 3, 4, 5), wantU: 12.5, wantP: 1},
+		{name: "shifted", a: durations(1, 2, 3, 4, 5, 6, 7, 8), b: durations(9, 10, 11, 12, 13, 14, 15, 16), wantU: 0, wantP: 0.000939},
+		{name: "empty", a: durations(1, 2), wantU: 0, wantP: 1},
+		{name: "all tied", a: durations(5, 5), b: durations(5, 5), wantU: 2, wantP: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, p := MannWhitney(tt.a, tt.b)
+			if u != tt.wantU || math.Abs(p-tt.wantP) > 1e-6 {
+				t.Fatalf("MannWhitney()=(%v, %v) want: (%v, %v)", u, p, tt.wantU, tt.wantP)
+			}
+		})
+	}
+}
+
+func TestNameDurations(t *testing.T) {
+	spans := []*cloudtrace.TraceSpan{
+		newSpan(1, 0, 0, ms(100)),
+		newSpan(2, 1, ms(10), ms(30)),
+		newSpan(3, 1, ms(40), ms(50)),
+		{SpanId: 4, ParentSpanId: 1, StartTime: timestamppb.New(testStart)},
+	}
+	for _, s := range spans[1:] {
+		s.Name = "db"
+	}
+
+	got := NameDurations(fromV1(spans).Spans)
+	if want := []time.Duration{ms(20), ms(10)}; !slices.Equal(got["db"], want) {
+		t.Fatalf("NameDurations()[db]=%v want: %v", got["db"], want)
+	}
+}
+
+func TestShifts(t *testing.T) {
+	baseline := map[string][]time.Duration{
+		"db":   {10 * time.Millisecond, 12 * time.Millisecond, 11 * time.Millisecond},
+		"http": {100 * time.Millisecond},
+		"old":  {time.Millisecond},
+	}
+	current := map[string][]time.Duration{
+		"db":   {30 * time.Millisecond, 32 * time.Millisecond, 31 * time.Millisecond},
+		"http": {101 * time.Millisecond},
+		"new":  {time.Millisecond},
+	}
+
+	got := Shifts(baseline, current)
+	var names []string
+	for _, s := range got {
+		names = append(names, s.Name)
+	}
+	want := []string{"db", "http", "new", "old"}
+	if len(names) != len(want) {
+		t.Fatalf("Shifts() names=%v want: %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Shifts() names=%v want: %v", names, want)
+		}
+	}
+	if got[0].P50Delta != 20*time.Millisecond {
+		t.Fatalf("P50Delta=%s want: 20ms", got[0].P50Delta)
+	}
+	if got[2].PValue != 1 || got[2].Baseline.Count != 0 {
+		t.Fatalf("Shifts()[2]=%+v", got[2])
+	}
+}
@@ -0,0 +1,169 @@
+package span
+
+import (
+	"sort"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// InFlight is the number of children running from an offset relative to the parent start until the next point
+// of the timeline.
+type InFlight struct {
+	Offset time.Duration `json:"offset"`
+	Count  int           `json:"count"`
+}
+
+// SpanRef identifies a span in a report.
+type SpanRef struct {
+	SpanID uint64 `json:"spanId"`
+	Name   string `json:"name"`
+}
+
+// Serialization is a run of sibling spans that ran back-to-back, each starting after the previous ones ended.
+// If they are independent, running them in parallel would take as long as the slowest one.
+type Serialization struct {
+	Spans []SpanRef `json:"spans"`
+	// Wall is the time from the first start to the last end of the run.
+	Wall time.Duration `json:"wall"`
+	// Savings estimates the time saved by running the spans in parallel.
+	Savings time.Duration `json:"savings"`
+}
+
+// Concurrency describes how parallel the children of a span ran.
+type Concurrency struct {
+	SpanID   uint64        `json:"spanId"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Children int           `json:"children"`
+	// Max is the maximum number of children running at once.
+	Max int `json:"max"`
+	// Average is the average number of children running over the parent lifetime.
+	Average        float64         `json:"average"`
+	Timeline       []InFlight      `json:"timeline"`
+	Serializations []Serialization `json:"serializations,omitempty"`
+}
+
+// Concurrencies computes the concurrency of the children of every span with children, in depth-first order.
+func Concurrencies(trace *model.Trace) []Concurrency {
+	var results []Concurrency
+	trace.DFS(func(s *model.Span) bool {
+		if len(s.Children) == 0 || !s.Timed() {
+			return true
+		}
+		results = append(results, newConcurrency(s))
+		return true
+	})
+	return results
+}
+
+func newConcurrency(s *model.Span) Concurrency {
+	c := Concurrency{
+		SpanID:   s.ID,
+		Name:     s.Name,
+		Duration: s.Duration(),
+		Children: len(s.Children),
+	}
+	parent := interval{start: s.Start, end: s.End}
+
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	var events []event
+	var busy time.Duration
+	for _, child := range s.Children {
+		if !child.Timed() {
+			continue
+		}
+		start, end := child.Start, child.End
+		events = append(events, event{at: start, delta: 1}, event{at: end, delta: -1})
+		clipped := interval{start: latest(start, parent.start), end: earliest(end, parent.end)}
+		if clipped.end.After(clipped.start) {
+			busy += clipped.duration()
+		}
+	}
+	// Ends sort before starts at the same time, so back-to-back children are not counted as concurrent.
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].at.Equal(events[j].at) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].at.Before(events[j].at)
+	})
+
+	count := 0
+	for _, e := range events {
+		count += e.delta
+		c.Max = max(c.Max, count)
+		offset := e.at.Sub(parent.start)
+		if last := len(c.Timeline) - 1; last >= 0 && c.Timeline[last].Offset == offset {
+			c.Timeline[last].Count = count
+			continue
+		}
+		c.Timeline = append(c.Timeline, InFlight{Offset: offset, Count: count})
+	}
+	if parent.duration() > 0 {
+		c.Average = float64(busy) / float64(parent.duration())
+	}
+
+	c.Serializations = serializations(s.Children)
+	return c
+}
+
+// serializations returns the runs of at least two children not overlapping any other child.
+func serializations(children []*model.Span) []Serialization {
+	var results []Serialization
+	var run []*model.Span
+	var end time.Time
+	flush := func() {
+		if len(run) >= 2 {
+			results = append(results, newSerialization(run))
+		}
+		run = nil
+	}
+
+	for i, child := range children {
+		if !child.Timed() {
+			continue
+		}
+		overlapsPrevious := i > 0 && child.Start.Before(end)
+		overlapsNext := i+1 < len(children) && children[i+1].Timed() && children[i+1].Start.Before(child.End)
+		if child.End.After(end) {
+			end = child.End
+		}
+		if overlapsPrevious || overlapsNext {
+			flush()
+			continue
+		}
+		run = append(run, child)
+	}
+	flush()
+	return results
+}
+
+func newSerialization(run []*model.Span) Serialization {
+	s := Serialization{Spans: make([]SpanRef, 0, len(run))}
+	var total, longest time.Duration
+	for _, span := range run {
+		s.Spans = append(s.Spans, SpanRef{SpanID: span.ID, Name: span.Name})
+		total += span.Duration()
+		longest = max(longest, span.Duration())
+	}
+	s.Wall = run[len(run)-1].End.Sub(run[0].Start)
+	s.Savings = max(total-longest, 0)
+	return s
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
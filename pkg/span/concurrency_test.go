@@ -0,0 +1,57 @@
+package span
+
+import (
+	"testing"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+)
+
+func TestConcurrencies(t *testing.T) {
+	// 2 and 3 run back-to-back, then 4 and 5 run in parallel, then 6 and 7 run back-to-back.
+	spans := []*cloudtrace.TraceSpan{
+		newSpan(1, 0, ms(0), ms(100)),
+		newSpan(2, 1, ms(0), ms(10)),
+		newSpan(3, 1, ms(10), ms(30)),
+		newSpan(4, 1, ms(30), ms(50)),
+		newSpan(5, 1, ms(40), ms(60)),
+		newSpan(6, 1, ms(60), ms(80)),
+		newSpan(7, 1, ms(80), ms(100)),
+	}
+
+	got := Concurrencies(fromV1(spans))
+	if len(got) != 1 {
+		t.Fatalf("unexpected number of results: %d", len(got))
+	}
+	c := got[0]
+	if c.Children != 6 || c.Max != 2 || c.Average != 1.1 {
+		t.Fatalf("Concurrencies()=%+v", c)
+	}
+
+	wantTimeline := []InFlight{
+		{Offset: 0, Count: 1}, {Offset: ms(10), Count: 1}, {Offset: ms(30), Count: 1}, {Offset: ms(40), Count: 2},
+		{Offset: ms(50), Count: 1}, {Offset: ms(60), Count: 1}, {Offset: ms(80), Count: 1}, {Offset: ms(100), Count: 0},
+	}
+	if len(c.Timeline) != len(wantTimeline) {
+		t.Fatalf("Timeline=%v want: %v", c.Timeline, wantTimeline)
+	}
+	for i := range wantTimeline {
+		if c.Timeline[i] != wantTimeline[i] {
+			t.Fatalf("Timeline=%v want: %v", c.Timeline, wantTimeline)
+		}
+	}
+
+	wantSerializations := []Serialization{
+		{Spans: []SpanRef{{SpanID: 2}, {SpanID: 3}}, Wall: ms(30), Savings: ms(10)},
+		{Spans: []SpanRef{{SpanID: 6}, {SpanID: 7}}, Wall: ms(40), Savings: ms(20)},
+	}
+	if len(c.Serializations) != len(wantSerializations) {
+		t.Fatalf("Serializations=%+v want: %+v", c.Serializations, wantSerializations)
+	}
+	for i, want := range wantSerializations {
+		s := c.Serializations[i]
+		if s.Wall != want.Wall || s.Savings != want.Savings || len(s.Spans) != len(want.Spans) ||
+			s.Spans[0] != want.Spans[0] || s.Spans[1] != want.Spans[1] {
+			t.Fatalf("Serializations[%d]=%+v want: %+v", i, s, want)
+		}
+	}
+}
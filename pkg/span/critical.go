@@ -0,0 +1,50 @@
+package span
+
+import (
+	"sort"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// CriticalPath returns the spans on the critical path of the trace, the chain of spans the root waited on, ordered
+// by start time. Starting from the end of a span, the child ending last before that point is on the path, then the
+// child ending last before that child started, and so on, recursively. Children ending after their parent are
+// clipped to the parent end.
+func CriticalPath(trace *model.Trace) []*model.Span {
+	tops := trace.Tops()
+	if len(tops) == 0 || !tops[0].Timed() {
+		return nil
+	}
+
+	var path []*model.Span
+	var walk func(s *model.Span)
+	walk = func(s *model.Span) {
+		path = append(path, s)
+		cursor := s.End
+		for {
+			var next *model.Span
+			var nextEnd time.Time
+			for _, child := range s.Children {
+				if !child.Timed() || !child.Start.Before(cursor) {
+					continue
+				}
+				end := earliest(child.End, cursor)
+				if next == nil || end.After(nextEnd) {
+					next, nextEnd = child, end
+				}
+			}
+			if next == nil {
+				return
+			}
+			walk(next)
+			cursor = next.Start
+		}
+	}
+	walk(tops[0])
+
+	sort.SliceStable(path, func(i, j int) bool {
+		return path[i].Start.Before(path[j].Start)
+	})
+	return path
+}
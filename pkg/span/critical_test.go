@@ -0,0 +1,29 @@
+package span
+
+import (
+	"testing"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+)
+
+func TestCriticalPath(t *testing.T) {
+	// 2 runs within 3, which ends last, then 5 runs after both. 7 is clipped to the end of 5 and 6 starts after it.
+	spans := []*cloudtrace.TraceSpan{
+		newSpan(1, 0, ms(0), ms(100)),
+		newSpan(2, 1, ms(15), ms(40)),
+		newSpan(3, 1, ms(10), ms(60)),
+		newSpan(4, 3, ms(20), ms(50)),
+		newSpan(5, 1, ms(60), ms(90)),
+		newSpan(6, 5, ms(70), ms(80)),
+		newSpan(7, 5, ms(65), ms(95)),
+	}
+
+	var got []uint64
+	for _, s := range CriticalPath(fromV1(spans)) {
+		got = append(got, s.ID)
+	}
+	want := []uint64{1, 3, 4, 5, 7}
+	if !equal(got, want) {
+		t.Fatalf("CriticalPath()=%v want: %v", got, want)
+	}
+}
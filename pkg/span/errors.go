@@ -0,0 +1,73 @@
+package span
+
+import (
+	"strconv"
+
+	"github.com/moshebe/gtrace/pkg/model"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorRules classify spans as failed according to their labels.
+type ErrorRules struct {
+	// MinHTTPStatus is the lowest HTTP status code of a failed span. Zero disables the HTTP status rule.
+	MinHTTPStatus int
+	// Labels mark a span as failed when set to any value but "false" or "0".
+	Labels []string
+	// StatusLabels hold gRPC status codes, by number or by name, any code but OK marks a span as failed.
+	StatusLabels []string
+}
+
+// DefaultErrorRules consider server errors, error and stack trace labels and non-OK gRPC statuses as failures,
+// so that a span fails exactly when its status, as model.LabelStatus derives it, is not OK.
+var DefaultErrorRules = ErrorRules{
+	MinHTTPStatus: model.MinHTTPErrorStatus,
+	Labels:        model.ErrorLabels,
+	StatusLabels:  model.StatusCodeLabels,
+}
+
+// IsError reports whether the span failed according to the default error rules.
+func IsError(span *model.Span) bool {
+	return DefaultErrorRules.IsError(span)
+}
+
+// IsError reports whether the span failed according to the rules.
+func (r ErrorRules) IsError(span *model.Span) bool {
+	return len(r.Reasons(span)) > 0
+}
+
+// Reasons returns the labels of the span that make it a failure according to the rules. An explicit status of
+// the span, such as the one of v2 spans, is seen as the status labels model.Span.Labels adds.
+func (r ErrorRules) Reasons(span *model.Span) map[string]string {
+	labels := span.Labels()
+	results := make(map[string]string)
+
+	if r.MinHTTPStatus > 0 {
+		value := labels[model.HTTPStatusCodeLabel]
+		if code, err := strconv.Atoi(value); err == nil && code >= r.MinHTTPStatus {
+			results[model.HTTPStatusCodeLabel] = value
+		}
+	}
+	for _, key := range r.Labels {
+		if value := labels[key]; model.LabelSet(value) {
+			results[key] = value
+		}
+	}
+	for _, key := range r.StatusLabels {
+		value := labels[key]
+		if code, found := model.ParseCode(value); found && code != codes.OK {
+			results[key] = value
+		}
+	}
+	return results
+}
+
+// CountErrors returns the number of failed spans according to the rules.
+func (r ErrorRules) CountErrors(spans []*model.Span) int {
+	count := 0
+	for _, s := range spans {
+		if r.IsError(s) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,54 @@
+package span
+
+import (
+	"testing"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+func TestIsError(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		rules  ErrorRules
+		want   bool
+	}{
+		{name: "no labels", rules: DefaultErrorRules, want: false},
+		{name: "server error", labels: map[string]string{model.HTTPStatusCodeLabel: "503"}, rules: DefaultErrorRules, want: true},
+		{name: "client error", labels: map[string]string{model.HTTPStatusCodeLabel: "404"}, rules: DefaultErrorRules, want: false},
+		{name: "client error with lower threshold", labels: map[string]string{model.HTTPStatusCodeLabel: "404"}, rules: ErrorRules{MinHTTPStatus: 400}, want: true},
+		{name: "error message", labels: map[string]string{model.ErrorMessageLabel: "boom"}, rules: DefaultErrorRules, want: true},
+		{name: "error false", labels: map[string]string{"error": "false"}, rules: DefaultErrorRules, want: false},
+		{name: "stack trace", labels: map[string]string{"/stacktrace": "main.go:42"}, rules: DefaultErrorRules, want: true},
+		{name: "grpc ok", labels: map[string]string{model.StatusCodeLabel: "0"}, rules: DefaultErrorRules, want: false},
+		{name: "grpc unavailable", labels: map[string]string{"rpc.grpc.status_code": "14"}, rules: DefaultErrorRules, want: true},
+		{name: "grpc status name", labels: map[string]string{"rpc.grpc.status_code": "UNAVAILABLE"}, rules: DefaultErrorRules, want: true},
+		{name: "http code in status label", labels: map[string]string{"rpc.grpc.status_code": "404"}, rules: DefaultErrorRules, want: false},
+		{name: "grpc ok name", labels: map[string]string{"/rpc/status_code": "OK"}, rules: DefaultErrorRules, want: false},
+		{name: "http rule disabled", labels: map[string]string{model.HTTPStatusCodeLabel: "500"}, rules: ErrorRules{}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fromV1([]*cloudtrace.TraceSpan{{SpanId: 1, Labels: tt.labels}}).Span(1)
+			if got := tt.rules.IsError(s); got != tt.want {
+				t.Fatalf("IsError(%v)=%v want: %v", tt.labels, got, tt.want)
+			}
+			// the default rules and the derived span status agree on failures.
+			if tt.rules.MinHTTPStatus == DefaultErrorRules.MinHTTPStatus && s.Status.OK() == tt.want {
+				t.Fatalf("status %+v disagrees with IsError=%v", s.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsErrorStatus(t *testing.T) {
+	s := &model.Span{ID: 1, Status: &model.Status{Code: 14, Message: "unavailable"}}
+	if !IsError(s) {
+		t.Fatalf("span with status %+v is not an error", s.Status)
+	}
+	s.Status = &model.Status{}
+	if IsError(s) {
+		t.Fatalf("span with OK status is an error")
+	}
+}
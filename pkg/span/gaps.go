@@ -0,0 +1,67 @@
+package span
+
+import (
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// Gap is an interval of a span not covered by any of its children.
+type Gap struct {
+	// Offset is the gap start relative to the span start.
+	Offset   time.Duration `json:"offset"`
+	Duration time.Duration `json:"duration"`
+}
+
+// SpanGaps lists the gaps of a span.
+type SpanGaps struct {
+	SpanID   uint64        `json:"spanId"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	// Uncovered is the total time of the span not covered by any child, including gaps below the threshold.
+	Uncovered time.Duration `json:"uncovered"`
+	Gaps      []Gap         `json:"gaps"`
+}
+
+// Gaps reports, for every span with children, in depth-first order, the intervals not covered by any child lasting
+// at least threshold. Spans without such gaps are skipped. Leaf spans are not reported, as they have no
+// instrumentation within them to begin with.
+func Gaps(trace *model.Trace, threshold time.Duration) []SpanGaps {
+	var results []SpanGaps
+	trace.DFS(func(s *model.Span) bool {
+		if len(s.Children) == 0 || !s.Timed() {
+			return true
+		}
+		if g := spanGaps(s, threshold); len(g.Gaps) > 0 {
+			results = append(results, g)
+		}
+		return true
+	})
+	return results
+}
+
+func spanGaps(s *model.Span, threshold time.Duration) SpanGaps {
+	result := SpanGaps{SpanID: s.ID, Name: s.Name, Duration: s.Duration()}
+	parent := interval{start: s.Start, end: s.End}
+
+	cursor := parent.start
+	addGap := func(end time.Time) {
+		if !end.After(cursor) {
+			return
+		}
+		d := end.Sub(cursor)
+		result.Uncovered += d
+		if d >= threshold {
+			result.Gaps = append(result.Gaps, Gap{Offset: cursor.Sub(parent.start), Duration: d})
+		}
+	}
+	for _, covered := range merge(intervals(s.Children)) {
+		if !covered.end.After(parent.start) || !covered.start.Before(parent.end) {
+			continue
+		}
+		addGap(covered.start)
+		cursor = latest(cursor, covered.end)
+	}
+	addGap(parent.end)
+	return result
+}
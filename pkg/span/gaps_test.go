@@ -0,0 +1,52 @@
+package span
+
+import (
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+)
+
+func TestGaps(t *testing.T) {
+	// Children of 1 cover 5-20, 20-40 and 30-50 (overlapping), then 90-95, and 3 has a single child covering it.
+	spans := []*cloudtrace.TraceSpan{
+		newSpan(1, 0, ms(0), ms(100)),
+		newSpan(2, 1, ms(5), ms(20)),
+		newSpan(3, 1, ms(20), ms(40)),
+		newSpan(4, 1, ms(30), ms(50)),
+		newSpan(5, 1, ms(90), ms(95)),
+		newSpan(6, 3, ms(20), ms(40)),
+	}
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		want      []Gap
+	}{
+		{name: "all gaps", threshold: 0, want: []Gap{{Offset: 0, Duration: ms(5)}, {Offset: ms(50), Duration: ms(40)}, {Offset: ms(95), Duration: ms(5)}}},
+		{name: "above threshold", threshold: ms(10), want: []Gap{{Offset: ms(50), Duration: ms(40)}}},
+		{name: "none above threshold", threshold: ms(50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Gaps(fromV1(spans), tt.threshold)
+			if len(tt.want) == 0 {
+				if len(got) != 0 {
+					t.Fatalf("Gaps()=%+v want: none", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].SpanID != 1 || got[0].Uncovered != ms(50) {
+				t.Fatalf("Gaps()=%+v", got)
+			}
+			if len(got[0].Gaps) != len(tt.want) {
+				t.Fatalf("Gaps()=%+v want: %+v", got[0].Gaps, tt.want)
+			}
+			for i := range tt.want {
+				if got[0].Gaps[i] != tt.want[i] {
+					t.Fatalf("Gaps()=%+v want: %+v", got[0].Gaps, tt.want)
+				}
+			}
+		})
+	}
+}
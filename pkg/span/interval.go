@@ -0,0 +1,53 @@
+package span
+
+import (
+	"sort"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+type interval struct {
+	start, end time.Time
+}
+
+func (i interval) duration() time.Duration {
+	return i.end.Sub(i.start)
+}
+
+// intervals returns the intervals of the spans with valid timestamps, ordered by start time.
+func intervals(spans []*model.Span) []interval {
+	results := make([]interval, 0, len(spans))
+	for _, s := range spans {
+		if !s.Timed() {
+			continue
+		}
+		results = append(results, interval{start: s.Start, end: s.End})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].start.Before(results[j].start) })
+	return results
+}
+
+// merge returns the union of intervals ordered by start time.
+func merge(sorted []interval) []interval {
+	var results []interval
+	for _, i := range sorted {
+		if n := len(results); n > 0 && !i.start.After(results[n-1].end) {
+			if i.end.After(results[n-1].end) {
+				results[n-1].end = i.end
+			}
+			continue
+		}
+		results = append(results, i)
+	}
+	return results
+}
+
+// wallTime returns the time during which at least one of the intervals is running.
+func wallTime(sorted []interval) time.Duration {
+	var total time.Duration
+	for _, i := range merge(sorted) {
+		total += i.duration()
+	}
+	return total
+}
@@ -0,0 +1,165 @@
+package span
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// Outlier scores how unusual a trace is compared to the other traces of its endpoint, the name of its root span.
+type Outlier struct {
+	ProjectID string        `json:"projectId,omitempty"`
+	TraceID   string        `json:"traceId"`
+	Endpoint  string        `json:"endpoint"`
+	Duration  time.Duration `json:"duration"`
+	// ZScore is the number of standard deviations the trace duration is away from the endpoint mean.
+	ZScore float64 `json:"zScore"`
+	// Structure is the structural difference of the trace from the endpoint baseline.
+	Structure float64 `json:"structure"`
+	// Score is the sum of the absolute z-score and the structural difference.
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// OutlierZScore is the absolute latency z-score above which a trace duration is reported as a reason.
+const OutlierZScore = 2
+
+// shape counts the spans and the maximum fan-out per span name of a trace.
+type shape struct {
+	counts  map[string]int
+	fanOuts map[string]int
+}
+
+func newShape(trace *model.Trace) shape {
+	s := shape{counts: make(map[string]int), fanOuts: make(map[string]int)}
+	trace.DFS(func(span *model.Span) bool {
+		s.counts[span.Name]++
+		s.fanOuts[span.Name] = max(s.fanOuts[span.Name], len(span.Children))
+		return true
+	})
+	return s
+}
+
+// Outliers scores every trace against the baseline of its endpoint: the median number of spans and fan-out per
+// span name among the traces of the endpoint, and the mean and standard deviation of their durations. Missing and
+// extra span names, repetitions and fan-outs at least twice or at most half the median, and durations
+// OutlierZScore standard deviations away from the mean are reported as reasons. The results are ordered by
+// descending score.
+func Outliers(traces []*model.Trace) []Outlier {
+	endpoints := make(map[string][]*model.Trace)
+	var order []string
+	for _, t := range traces {
+		root := t.Root()
+		if root == nil {
+			continue
+		}
+		if _, found := endpoints[root.Name]; !found {
+			order = append(order, root.Name)
+		}
+		endpoints[root.Name] = append(endpoints[root.Name], t)
+	}
+
+	var results []Outlier
+	for _, endpoint := range order {
+		results = append(results, endpointOutliers(endpoint, endpoints[endpoint])...)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
+}
+
+func endpointOutliers(endpoint string, traces []*model.Trace) []Outlier {
+	shapes := make([]shape, len(traces))
+	durations := make([]time.Duration, len(traces))
+	names := make(map[string]struct{})
+	for i, t := range traces {
+		shapes[i] = newShape(t)
+		start, end := t.Interval()
+		durations[i] = end.Sub(start)
+		for name := range shapes[i].counts {
+			names[name] = struct{}{}
+		}
+	}
+
+	medianCounts, medianFanOuts := make(map[string]int), make(map[string]int)
+	for name := range names {
+		counts, fanOuts := make([]int, len(shapes)), make([]int, len(shapes))
+		for i, s := range shapes {
+			counts[i], fanOuts[i] = s.counts[name], s.fanOuts[name]
+		}
+		medianCounts[name], medianFanOuts[name] = median(counts), median(fanOuts)
+	}
+	mean, stddev := meanStdDev(durations)
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	results := make([]Outlier, 0, len(traces))
+	for i, t := range traces {
+		o := Outlier{ProjectID: t.ProjectID, TraceID: t.TraceID, Endpoint: endpoint, Duration: durations[i], Reasons: []string{}}
+		for _, name := range sorted {
+			count, usual := shapes[i].counts[name], medianCounts[name]
+			switch {
+			case count == usual:
+			case usual == 0:
+				o.Structure++
+				o.Reasons = append(o.Reasons, fmt.Sprintf("extra span %s (%d)", name, count))
+			case count == 0:
+				o.Structure++
+				o.Reasons = append(o.Reasons, fmt.Sprintf("missing span %s (usually %d)", name, usual))
+			case unusual(count, usual):
+				o.Structure += math.Abs(float64(count-usual)) / float64(usual)
+				o.Reasons = append(o.Reasons, fmt.Sprintf("span %s repeated %d times (usually %d)", name, count, usual))
+			}
+
+			fanOut, usualFanOut := shapes[i].fanOuts[name], medianFanOuts[name]
+			if fanOut > 0 && usualFanOut > 0 && unusual(fanOut, usualFanOut) {
+				o.Structure += math.Abs(float64(fanOut-usualFanOut)) / float64(usualFanOut)
+				o.Reasons = append(o.Reasons, fmt.Sprintf("span %s fans out to %d children (usually %d)", name, fanOut, usualFanOut))
+			}
+		}
+
+		if stddev > 0 {
+			o.ZScore = float64(durations[i]-mean) / float64(stddev)
+		}
+		if math.Abs(o.ZScore) >= OutlierZScore {
+			o.Reasons = append(o.Reasons, fmt.Sprintf("took %s, %.1f standard deviations from the mean %s", durations[i], o.ZScore, mean))
+		}
+		o.Score = o.Structure + math.Abs(o.ZScore)
+		results = append(results, o)
+	}
+	return results
+}
+
+// unusual reports whether a value is at least twice or at most half the usual one.
+func unusual(value, usual int) bool {
+	return value >= 2*usual || 2*value <= usual
+}
+
+func median(values []int) int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func meanStdDev(durations []time.Duration) (time.Duration, time.Duration) {
+	if len(durations) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, d := range durations {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(durations))
+	var variance float64
+	for _, d := range durations {
+		variance += (float64(d) - mean) * (float64(d) - mean)
+	}
+	variance /= float64(len(durations))
+	return time.Duration(mean), time.Duration(math.Sqrt(variance))
+}
@@ -0,0 +1,65 @@
+package span
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+func TestOutliers(t *testing.T) {
+	newTrace := func(id string, duration time.Duration, queries int, cache bool) *model.Trace {
+		namedSpan := func(id, parent uint64, name string) *cloudtrace.TraceSpan {
+			s := newSpan(id, parent, 0, duration)
+			s.Name = name
+			return s
+		}
+		spans := []*cloudtrace.TraceSpan{namedSpan(1, 0, "/api/search")}
+		for i := 0; i < queries; i++ {
+			spans = append(spans, namedSpan(uint64(10+i), 1, "db"))
+		}
+		if cache {
+			spans = append(spans, namedSpan(2, 1, "cache"))
+		}
+		return model.FromV1(&cloudtrace.Trace{TraceId: id, Spans: spans})
+	}
+
+	var traces []*model.Trace
+	for i := 0; i < 10; i++ {
+		traces = append(traces, newTrace(fmt.Sprintf("usual-%d", i), time.Duration(100+i)*time.Millisecond, 2, true))
+	}
+	traces = append(traces,
+		newTrace("fan-out", 105*time.Millisecond, 8, true),
+		newTrace("missing-cache", 104*time.Millisecond, 2, false),
+		newTrace("slow", time.Second, 2, true),
+	)
+
+	got := Outliers(traces)
+	if len(got) != len(traces) {
+		t.Fatalf("unexpected number of results: %d", len(got))
+	}
+	top := make(map[string]Outlier)
+	for _, o := range got[:3] {
+		top[o.TraceID] = o
+	}
+	tests := []struct {
+		traceID string
+		reason  string
+	}{
+		{traceID: "fan-out", reason: "span db repeated 8 times (usually 2)"},
+		{traceID: "missing-cache", reason: "missing span cache (usually 1)"},
+		{traceID: "slow", reason: "took 1s"},
+	}
+	for _, tt := range tests {
+		o, found := top[tt.traceID]
+		if !found {
+			t.Fatalf("%s is not among the top outliers: %+v", tt.traceID, got[:3])
+		}
+		if !strings.Contains(strings.Join(o.Reasons, "; "), tt.reason) {
+			t.Fatalf("%s reasons=%v want: %q", tt.traceID, o.Reasons, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package span
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// Execution tells how repeated spans ran relative to each other.
+type Execution string
+
+const (
+	ExecutionSequential Execution = "sequential"
+	ExecutionParallel   Execution = "parallel"
+	ExecutionMixed      Execution = "mixed"
+)
+
+// DefaultRepetitionThreshold is the default minimal number of repeated sibling spans reported as a pattern.
+const DefaultRepetitionThreshold = 5
+
+// Repetition is a group of sibling spans with the same name, and similar label values if asked, typically an N+1
+// query issued by a handler.
+type Repetition struct {
+	ParentID   uint64            `json:"parentId"`
+	ParentName string            `json:"parentName"`
+	Name       string            `json:"name"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Count      int               `json:"count"`
+	Execution  Execution         `json:"execution"`
+	// Total is the sum of the span durations.
+	Total time.Duration `json:"total"`
+	// Wall is the time during which at least one of the spans was running.
+	Wall time.Duration `json:"wall"`
+	// Savings estimates the time saved by batching the spans into a single call lasting as long as the slowest one.
+	Savings time.Duration `json:"savings"`
+}
+
+var literalPattern = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"\\]|\\.)*"|\b\d+(?:\.\d+)?\b`)
+
+// NormalizeValue replaces the quoted strings and numbers of a label value with '?', so that queries differing only
+// by their parameters are alike.
+func NormalizeValue(value string) string {
+	return literalPattern.ReplaceAllString(value, "?")
+}
+
+// Repetitions finds the sibling spans sharing a name, and the normalized values of the given labels, repeated at
+// least threshold times, ordered by descending estimated savings. A threshold of zero or less means
+// DefaultRepetitionThreshold.
+func Repetitions(trace *model.Trace, threshold int, labels []string) []Repetition {
+	if threshold <= 0 {
+		threshold = DefaultRepetitionThreshold
+	}
+
+	var results []Repetition
+	trace.DFS(func(s *model.Span) bool {
+		type key struct{ name, labels string }
+		var order []key
+		grouped := make(map[key][]*model.Span)
+		values := make(map[key]map[string]string)
+		for _, child := range s.Children {
+			k := key{name: child.Name}
+			var normalized map[string]string
+			for _, label := range labels {
+				if value, found := child.Attributes[label]; found {
+					if normalized == nil {
+						normalized = make(map[string]string)
+					}
+					normalized[label] = NormalizeValue(value.String())
+					k.labels += label + "=" + normalized[label] + "\x00"
+				}
+			}
+			if _, found := grouped[k]; !found {
+				order = append(order, k)
+				values[k] = normalized
+			}
+			grouped[k] = append(grouped[k], child)
+		}
+
+		for _, k := range order {
+			group := grouped[k]
+			if len(group) < threshold {
+				continue
+			}
+			r := newRepetition(group)
+			r.ParentID, r.ParentName, r.Name, r.Labels = s.ID, s.Name, k.name, values[k]
+			results = append(results, r)
+		}
+		return true
+	})
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Savings > results[j].Savings })
+	return results
+}
+
+func newRepetition(spans []*model.Span) Repetition {
+	r := Repetition{Count: len(spans)}
+	sorted := intervals(spans)
+
+	var longest time.Duration
+	var end time.Time
+	overlaps, sequential := 0, 0
+	for idx, i := range sorted {
+		r.Total += i.duration()
+		longest = max(longest, i.duration())
+		if idx > 0 && i.start.Before(end) {
+			overlaps++
+		} else if idx > 0 {
+			sequential++
+		}
+		if i.end.After(end) {
+			end = i.end
+		}
+	}
+	switch {
+	case overlaps == 0:
+		r.Execution = ExecutionSequential
+	case sequential == 0:
+		r.Execution = ExecutionParallel
+	default:
+		r.Execution = ExecutionMixed
+	}
+
+	r.Wall = wallTime(sorted)
+	r.Savings = max(r.Wall-longest, 0)
+	return r
+}
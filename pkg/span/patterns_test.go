@@ -0,0 +1,78 @@
+package span
+
+import (
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+)
+
+func TestRepetitions(t *testing.T) {
+	querySpan := func(id, parent uint64, name string, from, to time.Duration, query string) *cloudtrace.TraceSpan {
+		s := newSpan(id, parent, from, to)
+		s.Name = name
+		if query != "" {
+			s.Labels = map[string]string{"/sql/query": query}
+		}
+		return s
+	}
+
+	spans := []*cloudtrace.TraceSpan{querySpan(1, 0, "handler", 0, time.Second, "")}
+	// 5 sequential queries of 10ms differing by their parameter, then 3 parallel calls of 20ms.
+	for i := uint64(0); i < 5; i++ {
+		from := time.Duration(i) * 10 * time.Millisecond
+		spans = append(spans, querySpan(10+i, 1, "db", from, from+10*time.Millisecond, "SELECT * FROM users WHERE id = "+string(rune('1'+i))))
+	}
+	spans = append(spans, querySpan(20, 1, "db", 60*time.Millisecond, 70*time.Millisecond, "SELECT * FROM orders"))
+	for i := uint64(0); i < 3; i++ {
+		spans = append(spans, querySpan(30+i, 1, "cache", 100*time.Millisecond, 120*time.Millisecond, ""))
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		labels    []string
+		want      []Repetition
+	}{
+		{
+			name:      "by name",
+			threshold: 3,
+			want: []Repetition{
+				{Name: "db", Count: 6, Execution: ExecutionSequential, Total: 60 * time.Millisecond, Wall: 60 * time.Millisecond, Savings: 50 * time.Millisecond},
+				{Name: "cache", Count: 3, Execution: ExecutionParallel, Total: 60 * time.Millisecond, Wall: 20 * time.Millisecond, Savings: 0},
+			},
+		},
+		{
+			name:      "by name and query",
+			threshold: 5,
+			labels:    []string{"/sql/query"},
+			want: []Repetition{
+				{Name: "db", Count: 5, Execution: ExecutionSequential, Total: 50 * time.Millisecond, Wall: 50 * time.Millisecond, Savings: 40 * time.Millisecond},
+			},
+		},
+		{name: "below threshold", threshold: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Repetitions(fromV1(spans), tt.threshold, tt.labels)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Repetitions()=%+v want: %+v", got, tt.want)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.ParentID != 1 || g.Name != want.Name || g.Count != want.Count || g.Execution != want.Execution ||
+					g.Total != want.Total || g.Wall != want.Wall || g.Savings != want.Savings {
+					t.Fatalf("Repetitions()[%d]=%+v want: %+v", i, g, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	got := NormalizeValue(`SELECT * FROM users WHERE id = 42 AND name = 'o''brien' AND score > 1.5`)
+	want := `SELECT * FROM users WHERE id = ? AND name = ? AND score > ?`
+	if got != want {
+		t.Fatalf("NormalizeValue()=%q want: %q", got, want)
+	}
+}
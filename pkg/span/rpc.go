@@ -0,0 +1,93 @@
+package span
+
+import (
+	"sort"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// RPCPair is a client span and the server span handling its call.
+type RPCPair struct {
+	Client *model.Span
+	Server *model.Span
+}
+
+// Overhead returns the time spent outside the server span, in the network, load balancers and queues.
+func (p RPCPair) Overhead() time.Duration {
+	return p.Client.Duration() - p.Server.Duration()
+}
+
+// RPCPairs pairs every RPC_CLIENT span with its RPC_SERVER children. Client spans without a server child, such as
+// calls to untraced services, are skipped.
+func RPCPairs(trace *model.Trace) []RPCPair {
+	var pairs []RPCPair
+	trace.DFS(func(s *model.Span) bool {
+		if s.Kind != model.KindClient {
+			return true
+		}
+		for _, child := range s.Children {
+			if child.Kind == model.KindServer {
+				pairs = append(pairs, RPCPair{Client: s, Server: child})
+			}
+		}
+		return true
+	})
+	return pairs
+}
+
+// RPCEdge aggregates the calls between a caller and a callee.
+type RPCEdge struct {
+	Caller   string        `json:"caller"`
+	Callee   string        `json:"callee"`
+	Client   DurationStats `json:"client"`
+	Server   DurationStats `json:"server"`
+	Overhead DurationStats `json:"overhead"`
+}
+
+// EdgeFunc names the caller and the callee of an RPC pair.
+type EdgeFunc func(pair RPCPair) (caller, callee string)
+
+// RPCEdges aggregates the pairs per caller and callee, ordered by descending mean overhead. The caller and callee
+// are named by fn.
+func RPCEdges(pairs []RPCPair, fn EdgeFunc) []RPCEdge {
+	type key struct{ caller, callee string }
+	type durations struct{ client, server, overhead []time.Duration }
+
+	var order []key
+	grouped := make(map[key]*durations)
+	for _, p := range pairs {
+		caller, callee := fn(p)
+		k := key{caller: caller, callee: callee}
+		d, found := grouped[k]
+		if !found {
+			d = &durations{}
+			grouped[k] = d
+			order = append(order, k)
+		}
+		d.client = append(d.client, p.Client.Duration())
+		d.server = append(d.server, p.Server.Duration())
+		d.overhead = append(d.overhead, p.Overhead())
+	}
+
+	edges := make([]RPCEdge, 0, len(order))
+	for _, k := range order {
+		d := grouped[k]
+		edges = append(edges, RPCEdge{
+			Caller:   k.caller,
+			Callee:   k.callee,
+			Client:   NewDurationStats(d.client),
+			Server:   NewDurationStats(d.server),
+			Overhead: NewDurationStats(d.overhead),
+		})
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		return edges[i].Overhead.Mean > edges[j].Overhead.Mean
+	})
+	return edges
+}
+
+// SpanNameEdge names the caller by the client span and the callee by the server span.
+func SpanNameEdge(pair RPCPair) (caller, callee string) {
+	return pair.Client.Name, pair.Server.Name
+}
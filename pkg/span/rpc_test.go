@@ -0,0 +1,42 @@
+package span
+
+import (
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+)
+
+func TestRPCEdges(t *testing.T) {
+	rpcSpan := func(id, parent uint64, name string, kind cloudtrace.TraceSpan_SpanKind, duration time.Duration) *cloudtrace.TraceSpan {
+		s := newSpan(id, parent, 0, duration)
+		s.Name, s.Kind = name, kind
+		return s
+	}
+	spans := []*cloudtrace.TraceSpan{
+		rpcSpan(1, 0, "handler", cloudtrace.TraceSpan_RPC_SERVER, time.Second),
+		rpcSpan(2, 1, "call/users", cloudtrace.TraceSpan_RPC_CLIENT, 100*time.Millisecond),
+		rpcSpan(3, 2, "users", cloudtrace.TraceSpan_RPC_SERVER, 60*time.Millisecond),
+		rpcSpan(4, 1, "call/users", cloudtrace.TraceSpan_RPC_CLIENT, 50*time.Millisecond),
+		rpcSpan(5, 4, "users", cloudtrace.TraceSpan_RPC_SERVER, 30*time.Millisecond),
+		rpcSpan(6, 1, "call/billing", cloudtrace.TraceSpan_RPC_CLIENT, 300*time.Millisecond),
+		rpcSpan(7, 6, "billing", cloudtrace.TraceSpan_RPC_SERVER, 100*time.Millisecond),
+		rpcSpan(8, 1, "call/external", cloudtrace.TraceSpan_RPC_CLIENT, 10*time.Millisecond),
+	}
+
+	pairs := RPCPairs(fromV1(spans))
+	if len(pairs) != 3 {
+		t.Fatalf("unexpected number of pairs: %d", len(pairs))
+	}
+
+	edges := RPCEdges(pairs, SpanNameEdge)
+	if len(edges) != 2 {
+		t.Fatalf("unexpected number of edges: %d", len(edges))
+	}
+	if edges[0].Callee != "billing" || edges[0].Overhead.Mean != 200*time.Millisecond {
+		t.Fatalf("unexpected first edge: %+v", edges[0])
+	}
+	if edges[1].Callee != "users" || edges[1].Overhead.Count != 2 || edges[1].Overhead.Mean != 30*time.Millisecond {
+		t.Fatalf("unexpected second edge: %+v", edges[1])
+	}
+}
@@ -0,0 +1,97 @@
+package span
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// DefaultServiceLabels are the labels a span service is derived from, in order of preference, when no rule is set.
+var DefaultServiceLabels = []string{
+	"service.name",
+	"g.co/gae/app/module",
+	"g.co/r/k8s_container/container_name",
+	"g.co/r/cloud_run_revision/service_name",
+}
+
+// ServiceRule derives a service from a label. If the rule has a pattern, the label value must match it and the
+// first capturing group, or the whole match without groups, is the service.
+type ServiceRule struct {
+	Label   string
+	Pattern *regexp.Regexp
+}
+
+// ParseServiceRule parses a rule in the form of "label" or "label=regex".
+func ParseServiceRule(rule string) (ServiceRule, error) {
+	label, pattern, found := strings.Cut(rule, "=")
+	if label == "" {
+		return ServiceRule{}, fmt.Errorf("invalid service rule %q, missing label", rule)
+	}
+	if !found {
+		return ServiceRule{Label: label}, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ServiceRule{}, fmt.Errorf("compile service rule %q: %w", rule, err)
+	}
+	return ServiceRule{Label: label, Pattern: re}, nil
+}
+
+func (r ServiceRule) apply(span *model.Span) string {
+	value := span.Attribute(r.Label)
+	if value == "" || r.Pattern == nil {
+		return value
+	}
+	match := r.Pattern.FindStringSubmatch(value)
+	switch len(match) {
+	case 0:
+		return ""
+	case 1:
+		return match[0]
+	default:
+		return match[1]
+	}
+}
+
+// ServiceResolver attributes spans to services according to its rules, the first matching rule wins.
+type ServiceResolver struct {
+	rules []ServiceRule
+}
+
+// NewServiceResolver returns a resolver with the given rules, or with rules for DefaultServiceLabels if none is given.
+func NewServiceResolver(rules ...ServiceRule) *ServiceResolver {
+	if len(rules) == 0 {
+		for _, label := range DefaultServiceLabels {
+			rules = append(rules, ServiceRule{Label: label})
+		}
+	}
+	return &ServiceResolver{rules: rules}
+}
+
+// Service returns the service of a single span, or an empty string if no rule matches.
+func (r *ServiceResolver) Service(span *model.Span) string {
+	for _, rule := range r.rules {
+		if service := rule.apply(span); service != "" {
+			return service
+		}
+	}
+	return ""
+}
+
+// Services returns the service of every span by id. Spans no rule matches inherit the service of their nearest
+// attributed ancestor, as spans of the same process often carry the resource labels on some spans only. Server
+// spans start a new process, so they inherit nothing and stay unknown when no rule matches.
+func (r *ServiceResolver) Services(trace *model.Trace) map[uint64]string {
+	services := make(map[uint64]string, len(trace.Spans))
+	trace.DFS(func(s *model.Span) bool {
+		service := r.Service(s)
+		if service == "" && s.Parent != nil && s.Kind != model.KindServer {
+			service = services[s.Parent.ID]
+		}
+		services[s.ID] = service
+		return true
+	})
+	return services
+}
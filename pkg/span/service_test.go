@@ -0,0 +1,55 @@
+package span
+
+import (
+	"testing"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+)
+
+func TestServices(t *testing.T) {
+	spans := []*cloudtrace.TraceSpan{
+		{SpanId: 1, Labels: map[string]string{"g.co/gae/app/module": "frontend"}},
+		{SpanId: 2, ParentSpanId: 1},
+		{SpanId: 3, ParentSpanId: 2, Labels: map[string]string{"/http/host": "users.internal.example.com"}},
+		{SpanId: 4, ParentSpanId: 3, Labels: map[string]string{"team/service": "billing"}},
+		{SpanId: 5, ParentSpanId: 99},
+		{SpanId: 6, ParentSpanId: 2, Kind: cloudtrace.TraceSpan_RPC_SERVER},
+		{SpanId: 7, ParentSpanId: 6},
+	}
+
+	hostRule, err := ParseServiceRule(`/http/host=^([^.]+)\.internal`)
+	if err != nil {
+		t.Fatalf("failed to parse rule: %v", err)
+	}
+	teamRule, err := ParseServiceRule("team/service")
+	if err != nil {
+		t.Fatalf("failed to parse rule: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		resolver *ServiceResolver
+		want     map[uint64]string
+	}{
+		{
+			name:     "default labels",
+			resolver: NewServiceResolver(),
+			want:     map[uint64]string{1: "frontend", 2: "frontend", 3: "frontend", 4: "frontend", 5: "", 6: "", 7: ""},
+		},
+		{
+			name:     "custom rules",
+			resolver: NewServiceResolver(teamRule, hostRule, ServiceRule{Label: "g.co/gae/app/module"}),
+			want:     map[uint64]string{1: "frontend", 2: "frontend", 3: "users", 4: "billing", 5: ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.resolver.Services(fromV1(spans))
+			for id, want := range tt.want {
+				if got[id] != want {
+					t.Fatalf("Services()[%d]=%q want: %q", id, got[id], want)
+				}
+			}
+		})
+	}
+}
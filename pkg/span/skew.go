@@ -0,0 +1,107 @@
+package span
+
+import (
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// DefaultHostLabels are the labels identifying the host a span was recorded on, in order of preference.
+var DefaultHostLabels = []string{
+	"g.co/r/k8s_container/pod_name",
+	"g.co/r/gce_instance/instance_id",
+	"host.name",
+	"/pid",
+}
+
+// SkewAdjustment is an offset applied to a span and all its descendants recorded on the same host.
+type SkewAdjustment struct {
+	SpanID uint64        `json:"spanId"`
+	Name   string        `json:"name"`
+	Host   string        `json:"host,omitempty"`
+	Offset time.Duration `json:"offset"`
+}
+
+// Host returns the value of the first host label found on the span, or an empty string if none is set.
+func Host(span *model.Span, labels []string) string {
+	for _, key := range labels {
+		if value := span.Attribute(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// AdjustSkew corrects clock skew between hosts in place, in the style of the Jaeger clock skew adjuster. A server
+// span whose parent is a client span on another host, or on an unknown one, must happen within its parent. If it
+// does not, it is shifted to the middle of the parent interval, or to the parent start if it is longer than its
+// parent, and the same offset is applied to its descendants recorded on the same host, or on an unknown one. It
+// returns the applied adjustments. When hostLabels is empty, DefaultHostLabels are used.
+func AdjustSkew(trace *model.Trace, hostLabels []string) []SkewAdjustment {
+	if len(hostLabels) == 0 {
+		hostLabels = DefaultHostLabels
+	}
+
+	var adjustments []SkewAdjustment
+	offsets := make(map[*model.Span]time.Duration)
+	trace.DFS(func(s *model.Span) bool {
+		if s.Parent == nil || !s.Timed() {
+			return true
+		}
+		host := Host(s, hostLabels)
+		if !isRemoteCall(s, host, hostLabels) {
+			if parentHost := Host(s.Parent, hostLabels); host == "" || parentHost == "" || host == parentHost {
+				offsets[s] = offsets[s.Parent]
+				shift(s, offsets[s])
+			}
+			return true
+		}
+
+		offset := skew(s, s.Parent)
+		offsets[s] = offset
+		if offset != 0 {
+			shift(s, offset)
+			adjustments = append(adjustments, SkewAdjustment{
+				SpanID: s.ID,
+				Name:   s.Name,
+				Host:   host,
+				Offset: offset,
+			})
+		}
+		return true
+	})
+	return adjustments
+}
+
+// isRemoteCall reports whether the span is the server side of an RPC issued by its parent from another host.
+func isRemoteCall(s *model.Span, host string, hostLabels []string) bool {
+	if s.Kind != model.KindServer || s.Parent.Kind != model.KindClient {
+		return false
+	}
+	return host == "" || host != Host(s.Parent, hostLabels)
+}
+
+// skew returns the offset moving the child into its parent interval, or zero if it is already within it or if
+// either span misses a timestamp.
+func skew(child, parent *model.Span) time.Duration {
+	if !child.Timed() || !parent.Timed() {
+		return 0
+	}
+	childDuration, parentDuration := child.Duration(), parent.Duration()
+	if childDuration > parentDuration {
+		return parent.Start.Sub(child.Start)
+	}
+	if !child.Start.Before(parent.Start) && !child.End.After(parent.End) {
+		return 0
+	}
+	latency := (parentDuration - childDuration) / 2
+	return parent.Start.Add(latency).Sub(child.Start)
+}
+
+func shift(span *model.Span, offset time.Duration) {
+	if offset == 0 || !span.Timed() {
+		return
+	}
+	span.Start = span.Start.Add(offset)
+	span.End = span.End.Add(offset)
+}
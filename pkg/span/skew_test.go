@@ -0,0 +1,95 @@
+package span
+
+import (
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+)
+
+func TestAdjustSkew(t *testing.T) {
+	hostSpan := func(id, parent uint64, kind cloudtrace.TraceSpan_SpanKind, host string, offset, duration time.Duration) *cloudtrace.TraceSpan {
+		s := newSpan(id, parent, offset, offset+duration)
+		s.Kind, s.Labels = kind, map[string]string{"host.name": host}
+		return s
+	}
+
+	tests := []struct {
+		name      string
+		spans     []*cloudtrace.TraceSpan
+		wantStart map[uint64]time.Duration
+		wantAdj   int
+	}{
+		{
+			name: "server ahead of client is centered with its local children",
+			spans: []*cloudtrace.TraceSpan{
+				hostSpan(1, 0, cloudtrace.TraceSpan_RPC_CLIENT, "a", 0, 100*time.Millisecond),
+				hostSpan(2, 1, cloudtrace.TraceSpan_RPC_SERVER, "b", -time.Second, 50*time.Millisecond),
+				hostSpan(3, 2, cloudtrace.TraceSpan_SPAN_KIND_UNSPECIFIED, "b", -time.Second+10*time.Millisecond, 10*time.Millisecond),
+			},
+			wantStart: map[uint64]time.Duration{1: 0, 2: 25 * time.Millisecond, 3: 35 * time.Millisecond},
+			wantAdj:   1,
+		},
+		{
+			name: "descendants on another host keep their clock",
+			spans: []*cloudtrace.TraceSpan{
+				hostSpan(1, 0, cloudtrace.TraceSpan_RPC_CLIENT, "a", 0, 100*time.Millisecond),
+				hostSpan(2, 1, cloudtrace.TraceSpan_RPC_SERVER, "b", -time.Second, 50*time.Millisecond),
+				hostSpan(3, 2, cloudtrace.TraceSpan_SPAN_KIND_UNSPECIFIED, "c", 10*time.Millisecond, 10*time.Millisecond),
+			},
+			wantStart: map[uint64]time.Duration{2: 25 * time.Millisecond, 3: 10 * time.Millisecond},
+			wantAdj:   1,
+		},
+		{
+			name: "client without timestamps is not a reference",
+			spans: []*cloudtrace.TraceSpan{
+				{SpanId: 1, Kind: cloudtrace.TraceSpan_RPC_CLIENT, Labels: map[string]string{"host.name": "a"}},
+				hostSpan(2, 1, cloudtrace.TraceSpan_RPC_SERVER, "b", time.Second, 50*time.Millisecond),
+			},
+			wantStart: map[uint64]time.Duration{2: time.Second},
+		},
+		{
+			name: "server longer than client is aligned to its start",
+			spans: []*cloudtrace.TraceSpan{
+				hostSpan(1, 0, cloudtrace.TraceSpan_RPC_CLIENT, "a", 0, 10*time.Millisecond),
+				hostSpan(2, 1, cloudtrace.TraceSpan_RPC_SERVER, "b", time.Second, 20*time.Millisecond),
+			},
+			wantStart: map[uint64]time.Duration{2: 0},
+			wantAdj:   1,
+		},
+		{
+			name: "server within client is kept",
+			spans: []*cloudtrace.TraceSpan{
+				hostSpan(1, 0, cloudtrace.TraceSpan_RPC_CLIENT, "a", 0, 100*time.Millisecond),
+				hostSpan(2, 1, cloudtrace.TraceSpan_RPC_SERVER, "b", 10*time.Millisecond, 50*time.Millisecond),
+			},
+			wantStart: map[uint64]time.Duration{2: 10 * time.Millisecond},
+		},
+		{
+			name: "same host is not adjusted",
+			spans: []*cloudtrace.TraceSpan{
+				hostSpan(1, 0, cloudtrace.TraceSpan_RPC_CLIENT, "a", 0, 100*time.Millisecond),
+				hostSpan(2, 1, cloudtrace.TraceSpan_RPC_SERVER, "a", -time.Second, 50*time.Millisecond),
+			},
+			wantStart: map[uint64]time.Duration{2: -time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := fromV1(tt.spans)
+			adjustments := AdjustSkew(trace, nil)
+			if len(adjustments) != tt.wantAdj {
+				t.Fatalf("unexpected adjustments: %+v", adjustments)
+			}
+			for _, s := range trace.Spans {
+				want, found := tt.wantStart[s.ID]
+				if !found {
+					continue
+				}
+				if got := s.Start.Sub(testStart); got != want {
+					t.Fatalf("span %d starts at %s want: %s", s.ID, got, want)
+				}
+			}
+		})
+	}
+}
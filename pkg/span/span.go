@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/moshebe/gtrace/pkg/filter"
+	"github.com/moshebe/gtrace/pkg/model"
 	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
 )
 
@@ -60,10 +61,17 @@ func FilterLabels(labels map[string]string, f *filter.Filter) map[string]string
 }
 
 func Format(spans []*cloudtrace.TraceSpan, format string, writer io.Writer) error {
+	return FormatServices(spans, nil, format, writer)
+}
+
+// FormatServices formats the spans as Format does, with the service of each span, looked up by span id, as
+// .Service.
+func FormatServices(spans []*cloudtrace.TraceSpan, services map[uint64]string, format string, writer io.Writer) error {
 	type ExtSpan struct {
 		*cloudtrace.TraceSpan
 		Duration   time.Duration
 		Start, End time.Time
+		Service    string
 	}
 
 	t, err := template.New("").Parse(format)
@@ -77,6 +85,7 @@ func Format(spans []*cloudtrace.TraceSpan, format string, writer io.Writer) erro
 			Start:     s.StartTime.AsTime(),
 			End:       s.EndTime.AsTime(),
 			Duration:  s.EndTime.AsTime().Sub(s.StartTime.AsTime()),
+			Service:   services[s.GetSpanId()],
 		})
 		if err != nil {
 			return err
@@ -98,6 +107,40 @@ func ListRootSpans(traces []*cloudtrace.Trace) map[string][]string {
 	return results
 }
 
+// Root returns the span without a parent that started first, or the first span if every span has a parent.
+func Root(spans []*cloudtrace.TraceSpan) *cloudtrace.TraceSpan {
+	var root *cloudtrace.TraceSpan
+	for _, s := range spans {
+		if s.GetParentSpanId() != 0 {
+			continue
+		}
+		if root == nil || s.GetStartTime().AsTime().Before(root.GetStartTime().AsTime()) {
+			root = s
+		}
+	}
+	if root == nil && len(spans) > 0 {
+		root = spans[0]
+	}
+	return root
+}
+
+// Interval returns the earliest start time and the latest end time among the given spans.
+func Interval(spans []*cloudtrace.TraceSpan) (start, end time.Time) {
+	for _, s := range spans {
+		if s.GetStartTime().IsValid() {
+			if st := s.GetStartTime().AsTime(); start.IsZero() || st.Before(start) {
+				start = st
+			}
+		}
+		if s.GetEndTime().IsValid() {
+			if et := s.GetEndTime().AsTime(); et.After(end) {
+				end = et
+			}
+		}
+	}
+	return start, end
+}
+
 func DurationSummary(span *cloudtrace.TraceSpan) string {
 	return fmt.Sprintf("%s (%d) - took %s",
 		span.GetName(),
@@ -124,23 +167,27 @@ func FilterMinDuration(spans []*cloudtrace.TraceSpan, threshold time.Duration) [
 	return results
 }
 
+// SubTree returns the given root span and all its descendants, keeping the original ordering of the spans.
+// Spans can be given in any order.
 func SubTree(spans []*cloudtrace.TraceSpan, rootID uint64) ([]*cloudtrace.TraceSpan, error) {
-	parents := map[uint64]struct{}{rootID: {}}
-	results := make([]*cloudtrace.TraceSpan, 0, len(spans))
+	tree := NewTree(spans)
+	root := tree.Span(rootID)
+	if root == nil {
+		return nil, errors.New("root span was not found")
+	}
+
+	// spans sharing an id are not part of the tree, only the first one is kept.
+	members := make(map[*cloudtrace.TraceSpan]struct{})
+	model.Walk(root, func(s *model.Span) bool {
+		members[tree.V1(s)] = struct{}{}
+		return true
+	})
+
+	results := make([]*cloudtrace.TraceSpan, 0, len(members))
 	for _, span := range spans {
-		if span.GetSpanId() == rootID {
+		if _, ok := members[span]; ok {
 			results = append(results, span)
-			continue
-		}
-		_, ok := parents[span.GetParentSpanId()]
-		if !ok {
-			continue
 		}
-		parents[span.GetSpanId()] = struct{}{}
-		results = append(results, span)
-	}
-	if len(results) == 0 {
-		return nil, errors.New("root span was not found")
 	}
 	return results, nil
 }
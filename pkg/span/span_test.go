@@ -0,0 +1,92 @@
+package span
+
+import (
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testSpans returns an unordered trace:
+//
+//	1
+//	├── 2
+//	│   ├── 4
+//	│   └── 5
+//	└── 3
+//	    └── 6
+//	8 (orphan, parent 7 is missing)
+func testSpans() []*cloudtrace.TraceSpan {
+	at := func(id, parent uint64) *cloudtrace.TraceSpan {
+		return newSpan(id, parent, ms(int(id)), time.Second)
+	}
+	return []*cloudtrace.TraceSpan{at(6, 3), at(4, 2), at(2, 1), at(8, 7), at(5, 2), at(1, 0), at(3, 1)}
+}
+
+// testStart is the time the offsets of test spans are relative to.
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func ms(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
+
+// newSpan returns a span running between the offsets from and to after testStart.
+func newSpan(id, parent uint64, from, to time.Duration) *cloudtrace.TraceSpan {
+	return &cloudtrace.TraceSpan{
+		SpanId:       id,
+		ParentSpanId: parent,
+		StartTime:    timestamppb.New(testStart.Add(from)),
+		EndTime:      timestamppb.New(testStart.Add(to)),
+	}
+}
+
+func fromV1(spans []*cloudtrace.TraceSpan) *model.Trace {
+	return model.FromV1(&cloudtrace.Trace{Spans: spans})
+}
+
+func equal(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSubTree(t *testing.T) {
+	tests := []struct {
+		name    string
+		spans   []*cloudtrace.TraceSpan
+		root    uint64
+		want    []uint64
+		wantErr bool
+	}{
+		{name: "whole tree", spans: testSpans(), root: 1, want: []uint64{6, 4, 2, 5, 1, 3}},
+		{name: "children listed before parent", spans: testSpans(), root: 2, want: []uint64{4, 2, 5}},
+		{name: "leaf", spans: testSpans(), root: 6, want: []uint64{6}},
+		{name: "orphan", spans: testSpans(), root: 8, want: []uint64{8}},
+		{name: "unknown root", spans: testSpans(), root: 42, wantErr: true},
+		{name: "cycle", spans: []*cloudtrace.TraceSpan{{SpanId: 1}, {SpanId: 2, ParentSpanId: 3}, {SpanId: 3, ParentSpanId: 2}}, root: 2, want: []uint64{2, 3}},
+		{name: "self parent", spans: []*cloudtrace.TraceSpan{{SpanId: 1}, {SpanId: 2, ParentSpanId: 2}, {SpanId: 3, ParentSpanId: 2}}, root: 2, want: []uint64{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, err := SubTree(tt.spans, tt.root)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SubTree(%d) error=%v wantErr: %v", tt.root, err, tt.wantErr)
+			}
+			var got []uint64
+			for _, s := range spans {
+				got = append(got, s.GetSpanId())
+			}
+			if !equal(got, tt.want) {
+				t.Fatalf("SubTree(%d)=%v want: %v", tt.root, got, tt.want)
+			}
+		})
+	}
+}
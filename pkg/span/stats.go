@@ -0,0 +1,94 @@
+package span
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// DurationStats summarizes a set of durations.
+type DurationStats struct {
+	Count int           `json:"count"`
+	Total time.Duration `json:"total"`
+	Mean  time.Duration `json:"mean"`
+	Min   time.Duration `json:"min"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+func NewDurationStats(durations []time.Duration) DurationStats {
+	if len(durations) == 0 {
+		return DurationStats{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return DurationStats{
+		Count: len(sorted),
+		Total: total,
+		Mean:  total / time.Duration(len(sorted)),
+		Min:   sorted[0],
+		P50:   percentile(sorted, 50),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// Percentile returns the p-th percentile, 0 < p <= 100, of the durations using the nearest-rank method.
+func Percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return percentile(sorted, p)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+// GroupStats summarizes the durations of the spans of a group.
+type GroupStats struct {
+	Group  string        `json:"group"`
+	Stats  DurationStats `json:"stats"`
+	Errors int           `json:"errors"`
+}
+
+// GroupDurations summarizes the span durations per group, as named by key, ordered by descending total duration.
+// Failed spans are counted according to the rules.
+func GroupDurations(spans []*model.Span, key func(*model.Span) string, rules ErrorRules) []GroupStats {
+	var order []string
+	grouped := make(map[string][]time.Duration)
+	errors := make(map[string]int)
+	for _, s := range spans {
+		group := key(s)
+		if _, found := grouped[group]; !found {
+			order = append(order, group)
+		}
+		grouped[group] = append(grouped[group], s.Duration())
+		if rules.IsError(s) {
+			errors[group]++
+		}
+	}
+
+	results := make([]GroupStats, 0, len(order))
+	for _, group := range order {
+		results = append(results, GroupStats{Group: group, Stats: NewDurationStats(grouped[group]), Errors: errors[group]})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Stats.Total > results[j].Stats.Total
+	})
+	return results
+}
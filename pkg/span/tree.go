@@ -0,0 +1,31 @@
+package span
+
+import (
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+// Tree indexes v1 spans by id and links them to their parent and children, whatever order the spans are given in.
+// It is a view of the spans as a model.Trace, which provides the walks: DFS, BFS, Ancestors, PathToRoot and LCA.
+type Tree struct {
+	*model.Trace
+	spans map[*model.Span]*cloudtrace.TraceSpan
+}
+
+// NewTree builds the tree of the spans. When spans share an id, the first one given is part of the tree.
+func NewTree(spans []*cloudtrace.TraceSpan) *Tree {
+	converted := make([]*model.Span, 0, len(spans))
+	t := &Tree{spans: make(map[*model.Span]*cloudtrace.TraceSpan, len(spans))}
+	for _, s := range spans {
+		c := model.FromV1Span(s)
+		t.spans[c] = s
+		converted = append(converted, c)
+	}
+	t.Trace = model.NewTrace("", "", converted)
+	return t
+}
+
+// V1 returns the original v1 span of a span of the tree.
+func (t *Tree) V1(s *model.Span) *cloudtrace.TraceSpan {
+	return t.spans[s]
+}
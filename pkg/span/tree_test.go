@@ -0,0 +1,66 @@
+package span
+
+import (
+	"testing"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+func spanIDs(spans []*model.Span) []uint64 {
+	var results []uint64
+	for _, s := range spans {
+		results = append(results, s.ID)
+	}
+	return results
+}
+
+func TestTree(t *testing.T) {
+	spans := testSpans()
+	tree := NewTree(spans)
+
+	if tree.Len() != 7 || len(tree.Roots) != 1 || len(tree.Orphans) != 1 {
+		t.Fatalf("unexpected tree of %d spans, %d roots and %d orphans", tree.Len(), len(tree.Roots), len(tree.Orphans))
+	}
+	if tree.V1(tree.Span(4)) != spans[1] {
+		t.Fatalf("V1(4) is not the original span")
+	}
+
+	var dfs, bfs []uint64
+	tree.DFS(func(s *model.Span) bool {
+		dfs = append(dfs, s.ID)
+		return true
+	})
+	tree.BFS(func(s *model.Span) bool {
+		bfs = append(bfs, s.ID)
+		return true
+	})
+	if want := []uint64{1, 2, 4, 5, 3, 6, 8}; !equal(dfs, want) {
+		t.Fatalf("DFS=%v want: %v", dfs, want)
+	}
+	if want := []uint64{1, 8, 2, 3, 4, 5, 6}; !equal(bfs, want) {
+		t.Fatalf("BFS=%v want: %v", bfs, want)
+	}
+
+	tests := []struct {
+		name string
+		got  []uint64
+		want []uint64
+	}{
+		{name: "ancestors", got: spanIDs(tree.Ancestors(5)), want: []uint64{2, 1}},
+		{name: "path to root", got: spanIDs(tree.PathToRoot(6)), want: []uint64{6, 3, 1}},
+		{name: "path of orphan", got: spanIDs(tree.PathToRoot(8)), want: []uint64{8}},
+		{name: "lca of cousins", got: spanIDs([]*model.Span{tree.LCA(4, 6)}), want: []uint64{1}},
+		{name: "lca of ancestor", got: spanIDs([]*model.Span{tree.LCA(5, 2)}), want: []uint64{2}},
+		{name: "unknown span", got: spanIDs(tree.Ancestors(42)), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !equal(tt.got, tt.want) {
+				t.Fatalf("got %v want: %v", tt.got, tt.want)
+			}
+		})
+	}
+	if tree.LCA(4, 8) != nil {
+		t.Fatalf("LCA(4, 8) is not nil for disjoint spans")
+	}
+}
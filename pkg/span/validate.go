@@ -0,0 +1,99 @@
+package span
+
+import (
+	"fmt"
+
+	"github.com/moshebe/gtrace/pkg/model"
+)
+
+type IssueKind string
+
+const (
+	IssueOrphan              IssueKind = "orphan"
+	IssueMultipleRoots       IssueKind = "multiple-roots"
+	IssueCycle               IssueKind = "cycle"
+	IssueDuplicateID         IssueKind = "duplicate-id"
+	IssueMissingTimestamp    IssueKind = "missing-timestamp"
+	IssueNonPositiveDuration IssueKind = "non-positive-duration"
+	IssueStartsBeforeParent  IssueKind = "starts-before-parent"
+	IssueEndsAfterParent     IssueKind = "ends-after-parent"
+)
+
+// IssueKinds lists all the kinds of issues Validate reports.
+var IssueKinds = []IssueKind{
+	IssueOrphan,
+	IssueMultipleRoots,
+	IssueCycle,
+	IssueDuplicateID,
+	IssueMissingTimestamp,
+	IssueNonPositiveDuration,
+	IssueStartsBeforeParent,
+	IssueEndsAfterParent,
+}
+
+// Issue is a structural problem found in a trace.
+type Issue struct {
+	Kind    IssueKind `json:"kind"`
+	SpanID  uint64    `json:"spanId,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Message string    `json:"message"`
+}
+
+func newIssue(kind IssueKind, span *model.Span, format string, args ...any) Issue {
+	return Issue{
+		Kind:    kind,
+		SpanID:  span.ID,
+		Name:    span.Name,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Validate reports the structural problems of a trace: orphan spans, whose parent is missing, for instance because
+// it belongs to a project without access, multiple roots, parent cycles, duplicate span ids, missing timestamps,
+// non-positive durations and children not contained in their parent interval.
+func Validate(trace *model.Trace) []Issue {
+	var issues []Issue
+
+	if len(trace.Roots) > 1 {
+		for _, s := range trace.Roots {
+			issues = append(issues, newIssue(IssueMultipleRoots, s, "one of %d root spans", len(trace.Roots)))
+		}
+	}
+	for _, s := range trace.Orphans {
+		issues = append(issues, newIssue(IssueOrphan, s, "parent span %d is missing", s.ParentID))
+	}
+	for _, s := range trace.Detached {
+		issues = append(issues, newIssue(IssueCycle, s, "span is part of or descends from a parent cycle"))
+	}
+	for _, s := range trace.Duplicates {
+		issues = append(issues, newIssue(IssueDuplicateID, s, "span id is used by another span"))
+	}
+
+	for _, s := range trace.Spans {
+		if !s.Timed() {
+			issues = append(issues, newIssue(IssueMissingTimestamp, s, "start or end time is missing"))
+			continue
+		}
+		if d := s.Duration(); d <= 0 {
+			issues = append(issues, newIssue(IssueNonPositiveDuration, s, "duration is %s", d))
+		}
+	}
+
+	trace.DFS(func(s *model.Span) bool {
+		parent := s.Parent
+		if parent == nil || !s.Timed() || !parent.Timed() {
+			return true
+		}
+		if s.Start.Before(parent.Start) {
+			issues = append(issues, newIssue(IssueStartsBeforeParent, s, "starts %s before parent span %d",
+				parent.Start.Sub(s.Start), parent.ID))
+		}
+		if s.End.After(parent.End) {
+			issues = append(issues, newIssue(IssueEndsAfterParent, s, "ends %s after parent span %d",
+				s.End.Sub(parent.End), parent.ID))
+		}
+		return true
+	})
+
+	return issues
+}
@@ -0,0 +1,82 @@
+package span
+
+import (
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *timestamppb.Timestamp {
+		return timestamppb.New(start.Add(offset))
+	}
+
+	tests := []struct {
+		name  string
+		spans []*cloudtrace.TraceSpan
+		want  []IssueKind
+	}{
+		{
+			name: "valid",
+			spans: []*cloudtrace.TraceSpan{
+				{SpanId: 1, StartTime: at(0), EndTime: at(time.Second)},
+				{SpanId: 2, ParentSpanId: 1, StartTime: at(0), EndTime: at(time.Second)},
+			},
+		},
+		{
+			name: "orphan and multiple roots",
+			spans: []*cloudtrace.TraceSpan{
+				{SpanId: 1, StartTime: at(0), EndTime: at(time.Second)},
+				{SpanId: 2, StartTime: at(0), EndTime: at(time.Second)},
+				{SpanId: 3, ParentSpanId: 4, StartTime: at(0), EndTime: at(time.Second)},
+			},
+			want: []IssueKind{IssueMultipleRoots, IssueMultipleRoots, IssueOrphan},
+		},
+		{
+			name: "child outside parent interval",
+			spans: []*cloudtrace.TraceSpan{
+				{SpanId: 1, StartTime: at(0), EndTime: at(time.Second)},
+				{SpanId: 2, ParentSpanId: 1, StartTime: at(-time.Millisecond), EndTime: at(2 * time.Second)},
+			},
+			want: []IssueKind{IssueStartsBeforeParent, IssueEndsAfterParent},
+		},
+		{
+			name: "bad timestamps",
+			spans: []*cloudtrace.TraceSpan{
+				{SpanId: 1, StartTime: at(0), EndTime: at(time.Second)},
+				{SpanId: 2, ParentSpanId: 1, StartTime: at(0)},
+				{SpanId: 3, ParentSpanId: 1, StartTime: at(time.Second), EndTime: at(0)},
+			},
+			want: []IssueKind{IssueMissingTimestamp, IssueNonPositiveDuration},
+		},
+		{
+			name: "cycle and duplicate",
+			spans: []*cloudtrace.TraceSpan{
+				{SpanId: 1, StartTime: at(0), EndTime: at(time.Second)},
+				{SpanId: 1, StartTime: at(0), EndTime: at(time.Second)},
+				{SpanId: 2, ParentSpanId: 2, StartTime: at(0), EndTime: at(time.Second)},
+			},
+			want: []IssueKind{IssueCycle, IssueDuplicateID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Validate(fromV1(tt.spans))
+			var got []IssueKind
+			for _, issue := range issues {
+				got = append(got, issue.Kind)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Validate()=%v want: %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Validate()=%v want: %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
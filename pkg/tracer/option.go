@@ -5,9 +5,26 @@ import (
 	"time"
 
 	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"github.com/moshebe/gtrace/pkg/cache"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+type Option func(t *Tracer)
+
+// WithCache serves traces and list results from the given cache when possible and stores the fetched ones in it.
+func WithCache(c *cache.Cache) Option {
+	return func(t *Tracer) {
+		t.cache = c
+	}
+}
+
+// WithRefresh always fetches from the API, ignoring cached values, while still storing the results in the cache.
+func WithRefresh() Option {
+	return func(t *Tracer) {
+		t.refresh = true
+	}
+}
+
 type ListOption func(request *cloudtrace.ListTracesRequest)
 
 func WithStartTime(start time.Time) ListOption {
@@ -2,33 +2,69 @@ package tracer
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	traceapi "cloud.google.com/go/trace/apiv1"
 	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	traceapiv2 "cloud.google.com/go/trace/apiv2"
+	tracev2 "cloud.google.com/go/trace/apiv2/tracepb"
+	"github.com/moshebe/gtrace/pkg/cache"
+	"github.com/moshebe/gtrace/pkg/model"
+	"github.com/moshebe/gtrace/pkg/span"
 	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
 )
 
+// SettleTime is how long after its end a trace may still receive spans. Traces and list windows ending more
+// recently are not cached, as they may be incomplete.
+const SettleTime = 10 * time.Minute
+
 type Tracer struct {
-	client *traceapi.Client
+	client  *traceapi.Client
+	cache   *cache.Cache
+	refresh bool
+
+	// writer is the v2 API client, which is write-only, created on first use.
+	mu     sync.Mutex
+	writer *traceapiv2.Client
 }
 
-func NewTracer(ctx context.Context) (*Tracer, error) {
+func NewTracer(ctx context.Context, opts ...Option) (*Tracer, error) {
 	client, err := traceapi.NewClient(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("new client: %w", err)
 	}
 
-	return &Tracer{client: client}, nil
+	t := &Tracer{client: client}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t, nil
 }
 
 // Get retrieve tracer from a specific project by the tracer id.
 func (t *Tracer) Get(ctx context.Context, projectID, traceID string) (*cloudtrace.Trace, error) {
-	return t.client.GetTrace(ctx, &cloudtrace.GetTraceRequest{
+	key := cache.TraceKey(projectID, traceID)
+	trace := &cloudtrace.Trace{}
+	if t.cached(key, trace) {
+		return trace, nil
+	}
+
+	trace, err := t.client.GetTrace(ctx, &cloudtrace.GetTraceRequest{
 		ProjectId: projectID,
 		TraceId:   traceID,
 	})
+	if err != nil {
+		return nil, err
+	}
+	if _, end := span.Interval(trace.GetSpans()); settled(end) {
+		t.store(key, trace)
+	}
+	return trace, nil
 }
 
 // MultiGet retrieve tracer from multiple projects by the tracer id and aggregate the spans.
@@ -51,18 +87,97 @@ func (t *Tracer) MultiGet(ctx context.Context, projects []string, traceIDs []str
 	return result, nil
 }
 
-// List returns of a list of traces that match the specified options conditions.
-func (t *Tracer) List(ctx context.Context, projectID string, limit int32, opts ...ListOption) ([]*cloudtrace.Trace, error) {
-	req := &cloudtrace.ListTracesRequest{
+// Patch sends the traces to a specific project. Spans of an existing trace id are merged into the stored trace and
+// spans with an existing span id replace the stored ones. The given traces are left untouched.
+func (t *Tracer) Patch(ctx context.Context, projectID string, traces ...*cloudtrace.Trace) error {
+	patched := make([]*cloudtrace.Trace, 0, len(traces))
+	for _, trace := range traces {
+		trace = proto.Clone(trace).(*cloudtrace.Trace)
+		trace.ProjectId = projectID
+		patched = append(patched, trace)
+	}
+	return t.client.PatchTraces(ctx, &cloudtrace.PatchTracesRequest{
 		ProjectId: projectID,
-		View:      cloudtrace.ListTracesRequest_COMPLETE,
+		Traces:    &cloudtrace.Traces{Traces: patched},
+	})
+}
+
+// Write sends v2 spans, which unlike v1 spans carry a status, time events and links, to a specific project.
+// The project part of the span names is replaced by the given project, leaving the given spans untouched.
+func (t *Tracer) Write(ctx context.Context, projectID string, spans ...*tracev2.Span) error {
+	written := make([]*tracev2.Span, 0, len(spans))
+	for _, s := range spans {
+		_, traceID, spanID, err := model.ParseV2Name(s.GetName())
+		if err != nil {
+			return err
+		}
+		s = proto.Clone(s).(*tracev2.Span)
+		s.Name = model.V2Name(projectID, traceID, spanID)
+		written = append(written, s)
 	}
-	for _, opt := range opts {
-		opt(req)
+
+	writer, err := t.v2Client(ctx)
+	if err != nil {
+		return err
+	}
+	return writer.BatchWriteSpans(ctx, &tracev2.BatchWriteSpansRequest{
+		Name:  "projects/" + projectID,
+		Spans: written,
+	})
+}
+
+func (t *Tracer) v2Client(ctx context.Context) (*traceapiv2.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.writer != nil {
+		return t.writer, nil
+	}
+	writer, err := traceapiv2.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("new v2 client: %w", err)
+	}
+	t.writer = writer
+	return writer, nil
+}
+
+// List returns of a list of traces that match the specified options conditions. Results are cached only for windows
+// with a fixed start and an end older than SettleTime, since open and recent windows still change.
+func (t *Tracer) List(ctx context.Context, projectID string, limit int32, opts ...ListOption) ([]*cloudtrace.Trace, error) {
+	req := newListRequest(projectID, opts)
+	cacheable := req.GetStartTime().IsValid() && req.GetEndTime().IsValid() && settled(req.GetEndTime().AsTime())
+	key, err := listKey(req, limit)
+	if err != nil {
+		return nil, err
+	}
+	cached := &cloudtrace.ListTracesResponse{}
+	if cacheable && t.cached(key, cached) {
+		return cached.GetTraces(), nil
 	}
 
 	var traces []*cloudtrace.Trace
-	it := t.client.ListTraces(ctx, req)
+	err = t.Walk(ctx, projectID, limit, func(trace *cloudtrace.Trace) error {
+		traces = append(traces, trace)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		t.store(key, &cloudtrace.ListTracesResponse{Traces: traces})
+	}
+	return traces, nil
+}
+
+// settled reports whether end is older than SettleTime.
+func settled(end time.Time) bool {
+	return !end.IsZero() && time.Since(end) > SettleTime
+}
+
+// Walk calls fn for every trace that match the specified options conditions, page after page, until limit traces
+// were visited. A non-positive limit walks all the matching traces. Walking stops at the first error returned by fn.
+func (t *Tracer) Walk(ctx context.Context, projectID string, limit int32, fn func(*cloudtrace.Trace) error, opts ...ListOption) error {
+	it := t.client.ListTraces(ctx, newListRequest(projectID, opts))
 	var count int32 = 0
 	for {
 		trace, err := it.Next()
@@ -70,20 +185,66 @@ func (t *Tracer) List(ctx context.Context, projectID string, limit int32, opts .
 			break
 		}
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err = fn(trace); err != nil {
+			return err
 		}
 		count++
-		traces = append(traces, trace)
-		if count >= limit {
+		if limit > 0 && count >= limit {
 			break
 		}
 	}
 
-	return traces, nil
+	return nil
+}
+
+func newListRequest(projectID string, opts []ListOption) *cloudtrace.ListTracesRequest {
+	req := &cloudtrace.ListTracesRequest{
+		ProjectId: projectID,
+		View:      cloudtrace.ListTracesRequest_COMPLETE,
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	return req
 }
 
-// Close closes the inner client connection to the API service.
+// listKey returns the cache key of a list request, which is the hash of all its conditions and the limit.
+func listKey(req *cloudtrace.ListTracesRequest, limit int32) (string, error) {
+	out, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+	sum := sha256.Sum256(out)
+	return fmt.Sprintf("list/%s/%x/%d", req.GetProjectId(), sum, limit), nil
+}
+
+// cached loads the value stored under key into m unless the tracer has no cache or was asked to refresh it.
+func (t *Tracer) cached(key string, m proto.Message) bool {
+	if t.cache == nil || t.refresh {
+		return false
+	}
+	found, err := t.cache.Get(key, m)
+	return err == nil && found
+}
+
+// store saves m in the cache. Caching is best-effort so failures are ignored.
+func (t *Tracer) store(key string, m proto.Message) {
+	if t.cache == nil {
+		return
+	}
+	_ = t.cache.Put(key, m)
+}
+
+// Close closes the inner client connections to the API service.
 func (t *Tracer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.writer != nil {
+		_ = t.writer.Close()
+		t.writer = nil
+	}
 	if t.client == nil {
 		return nil
 	}
@@ -0,0 +1,12 @@
+package tracer
+
+import "fmt"
+
+// ConsoleURL returns the URL of a trace in the Cloud console. The project may be empty.
+func ConsoleURL(projectID, traceID string) string {
+	projectPath := ""
+	if projectID != "" {
+		projectPath += "&project=" + projectID
+	}
+	return fmt.Sprintf("https://console.cloud.google.com/traces/list?tid=%s%s", traceID, projectPath)
+}